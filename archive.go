@@ -0,0 +1,109 @@
+package ip2location
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+const no_bin_in_zip string = "No BIN file found in the ZIP archive."
+
+// BIN databases address their content with 32-bit offsets, so larger files in an archive are not BIN databases
+const max_bin_size = 1 << 32
+
+// memory reserved up front for a BIN file of an archive, whose size in the archive may be wrong
+const max_zip_prealloc = 1 << 26
+
+// OpenDBFromZip takes the path to a ZIP archive as distributed by IP2Location, finds the BIN database file
+// inside it and decompresses it into memory. Lookups are then served from memory as with OpenDBInMemory.
+func OpenDBFromZip(zippath string) (*DB, error) {
+	z, err := zip.OpenReader(zippath)
+	if err != nil {
+		return nil, err
+	}
+	defer z.Close()
+
+	return openzip(&z.Reader)
+}
+
+// open the first BIN file of the archive
+func openzip(z *zip.Reader) (*DB, error) {
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() || !strings.EqualFold(pathext(f.Name), ".bin") {
+			continue
+		}
+
+		size := f.UncompressedSize64
+		if size > max_bin_size {
+			return nil, fmt.Errorf("%s: %w: %d bytes is too large for a BIN file", f.Name, ErrInvalidDatabase, size)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		// the buffer grows with the data read rather than trusting the size in the archive
+		prealloc := size
+		if prealloc > max_zip_prealloc {
+			prealloc = max_zip_prealloc
+		}
+		buf := bytes.NewBuffer(make([]byte, 0, prealloc))
+		_, err = io.Copy(buf, io.LimitReader(rc, int64(size)+1))
+		if cerr := rc.Close(); err == nil {
+			err = cerr // reports a checksum mismatch
+		}
+		if err == nil && uint64(buf.Len()) != size {
+			err = fmt.Errorf("%w: the file does not have the %d bytes the archive gives as its size", ErrInvalidDatabase, size)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		return OpenDBWithBytes(buf.Bytes())
+	}
+	return nil, errors.New(no_bin_in_zip)
+}
+
+// extension of a path inside an archive or fs.FS, which always use forward slashes
+func pathext(name string) string {
+	name = name[strings.LastIndexByte(name, '/')+1:]
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[i:]
+	}
+	return ""
+}
+
+// OpenDBFS takes a file system such as an embed.FS and the name of an IP2Location BIN database file in it.
+// Files that support random access, as those of embed.FS and os.DirFS do, are read in place. Other files are
+// loaded into memory. A name ending in .zip is opened as a ZIP archive holding the BIN database file.
+func OpenDBFS(fsys fs.FS, name string) (*DB, error) {
+	if strings.EqualFold(pathext(name), ".zip") {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		return openzip(z)
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := f.(DBReader); ok {
+		return OpenDBWithReader(r)
+	}
+
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return OpenDBWithBytes(data)
+}
@@ -0,0 +1,143 @@
+package ip2location_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"errors"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// checks lookups of a DB against the DB of the test ranges
+func checktestdb(t *testing.T, db *ip2location.DB) {
+	t.Helper()
+
+	local := opentestdb(t)
+	for _, ip := range []string{"8.8.8.8", "10.1.2.3", "2001:db8::1", "192.0.2.1"} {
+		want, wanterr := local.Get_all(ip)
+		if got, err := db.Get_all(ip); got != want || err != wanterr {
+			t.Errorf("Get_all(%s) = %+v, %v, want %+v, %v", ip, got, err, want, wanterr)
+		}
+	}
+}
+
+// returns a ZIP archive holding the data as IP2LOCATION.BIN with the uncompressed size given in its header
+func zipwithsize(t *testing.T, data []byte, size uint64) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := fw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+	w, err := z.CreateRaw(&zip.FileHeader{
+		Name: "IP2LOCATION.BIN", Method: zip.Deflate, CRC32: crc32.ChecksumIEEE(data),
+		CompressedSize64: uint64(compressed.Len()), UncompressedSize64: size,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(compressed.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestOpenDBFromZip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "DB26.ZIP")
+	if err := os.WriteFile(path, zipbin(t, testbin(t)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := ip2location.OpenDBFromZip(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	checktestdb(t, db)
+
+	nobin := filepath.Join(dir, "NOBIN.ZIP")
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+	if _, err := z.Create("README.TXT"); err != nil {
+		t.Fatal(err)
+	}
+	z.Close()
+	if err := os.WriteFile(nobin, b.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ip2location.OpenDBFromZip(nobin); err == nil {
+		t.Error("OpenDBFromZip of an archive without a BIN file succeeded")
+	}
+	if _, err := ip2location.OpenDBFromZip(filepath.Join(dir, "MISSING.ZIP")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("OpenDBFromZip of a missing file = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestOpenDBFromZipWrongSize(t *testing.T) {
+	data := testbin(t)
+	for _, size := range []uint64{1 << 40, 1 << 31, uint64(len(data)) - 1, uint64(len(data)) + 1} {
+		fsys := fstest.MapFS{"DB.ZIP": {Data: zipwithsize(t, data, size)}}
+		if _, err := ip2location.OpenDBFS(fsys, "DB.ZIP"); err == nil {
+			t.Errorf("a BIN file of %d bytes given as %d bytes in the archive was opened", len(data), size)
+		}
+	}
+
+	fsys := fstest.MapFS{"DB.ZIP": {Data: zipwithsize(t, data, uint64(len(data)))}}
+	db, err := ip2location.OpenDBFS(fsys, "DB.ZIP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	checktestdb(t, db)
+}
+
+func TestOpenDBFS(t *testing.T) {
+	data := testbin(t)
+	fsys := fstest.MapFS{
+		"data/IP2LOCATION.BIN": {Data: data},
+		"data/DB26.ZIP":        {Data: zipbin(t, data)},
+	}
+	for _, name := range []string{"data/IP2LOCATION.BIN", "data/DB26.ZIP"} {
+		db, err := ip2location.OpenDBFS(fsys, name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		checktestdb(t, db)
+		db.Close()
+	}
+
+	// os.DirFS files are read in place
+	path := writebin(t, data)
+	db, err := ip2location.OpenDBFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checktestdb(t, db)
+	db.Close()
+
+	for _, name := range []string{"data/MISSING.BIN", "data/MISSING.ZIP"} {
+		if _, err := ip2location.OpenDBFS(fsys, name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("OpenDBFS(%s) = %v, want fs.ErrNotExist", name, err)
+		}
+	}
+}
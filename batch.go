@@ -0,0 +1,156 @@
+package ip2location
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// The BatchResult struct stores the outcome of one lookup in a batch.
+type BatchResult struct {
+	IP     string
+	Record IP2Locationrecord
+	Err    error
+}
+
+// number of workers to use when the caller leaves it to us
+func batchworkers(workers int) int {
+	if workers <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return workers
+}
+
+// LookupBatch will return the selected geolocation fields for every IP address, in the same order as ips.
+// The lookups are spread over the given number of workers, or one per CPU if workers is zero or less.
+// Each result carries its own error so a bad address does not fail the whole batch. If ctx is cancelled,
+// the lookups not done yet get the context error and LookupBatch returns it too.
+func (d *DB) LookupBatch(ctx context.Context, ips []string, fields Field, workers int) ([]BatchResult, error) {
+	results := make([]BatchResult, len(ips))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	workers = batchworkers(workers)
+	if workers > len(ips) {
+		workers = len(ips)
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res := &results[i]
+				iptype, ipno, ipindex := d.checkip(res.IP)
+				res.Err = d.queryinto(iptype, ipno, ipindex, fields, &res.Record, nil)
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < len(ips); next++ {
+		results[next].IP = ips[next]
+		select {
+		case jobs <- next:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if next < len(ips) {
+		for i := next; i < len(ips); i++ {
+			results[i].IP = ips[i]
+			results[i].Err = ctx.Err()
+		}
+		return results, ctx.Err()
+	}
+	return results, nil
+}
+
+// LookupStream reads IP addresses from in and writes their results to the returned channel in the same order.
+// The lookups are spread over the given number of workers, or one per CPU if workers is zero or less.
+// The returned channel is closed once in is closed and all its results are written, or when ctx is cancelled.
+// No lookup of the stream is running once it is closed, so the DB can then be closed.
+func (d *DB) LookupStream(ctx context.Context, in <-chan string, fields Field, workers int) <-chan BatchResult {
+	workers = batchworkers(workers)
+	out := make(chan BatchResult, workers)
+
+	type job struct {
+		ip  string
+		res chan BatchResult
+	}
+	jobs := make(chan job, workers)
+	pending := make(chan chan BatchResult, workers*4) // results still to be written out, in input order
+
+	// feed the workers while queuing each result slot in input order
+	go func() {
+		defer close(pending)
+		defer close(jobs)
+
+		for {
+			var ip string
+			var ok bool
+			select {
+			case ip, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			res := make(chan BatchResult, 1)
+			select {
+			case pending <- res:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job{ip: ip, res: res}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				r := BatchResult{IP: j.ip}
+				iptype, ipno, ipindex := d.checkip(j.ip)
+				r.Err = d.queryinto(iptype, ipno, ipindex, fields, &r.Record, nil)
+				j.res <- r
+			}
+		}()
+	}
+
+	// write the results out in input order, closing out only once the workers are done with the DB
+	go func() {
+		defer func() {
+			wg.Wait()
+			close(out)
+		}()
+
+		for res := range pending {
+			var r BatchResult
+			select {
+			case r = <-res:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case out <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,236 @@
+package ip2location_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// returns addresses spread over the test ranges and the gaps between them, with an invalid one every 7
+func batchips(n int) []string {
+	ips := make([]string, n)
+	for i := range ips {
+		switch i % 7 {
+		case 0:
+			ips[i] = "bad"
+		case 1, 2:
+			ips[i] = fmt.Sprintf("8.8.8.%d", i%256)
+		case 3:
+			ips[i] = fmt.Sprintf("10.%d.%d.1", i%256, (i/256)%256)
+		case 4:
+			ips[i] = fmt.Sprintf("2001:db8::%x", i)
+		default:
+			ips[i] = fmt.Sprintf("192.0.%d.%d", (i/256)%256, i%256)
+		}
+	}
+	return ips
+}
+
+// checks a result against a lookup of its address with Query
+func checkresult(db *ip2location.DB, i int, ip string, r ip2location.BatchResult) error {
+	if r.IP != ip {
+		return fmt.Errorf("result %d is for %q, want %q", i, r.IP, ip)
+	}
+	want, err := db.Query(ip, ip2location.FieldAll)
+	if r.Err != err {
+		return fmt.Errorf("result %d (%s) error = %v, want %v", i, ip, r.Err, err)
+	}
+	if r.Record != want {
+		return fmt.Errorf("result %d (%s) = %+v, want %+v", i, ip, r.Record, want)
+	}
+	return nil
+}
+
+func TestLookupBatch(t *testing.T) {
+	db := opentestdb(t)
+	ips := batchips(1000)
+
+	for _, workers := range []int{0, 1, 3, 5000} {
+		results, err := db.LookupBatch(context.Background(), ips, ip2location.FieldAll, workers)
+		if err != nil {
+			t.Fatalf("workers %d: %v", workers, err)
+		}
+		if len(results) != len(ips) {
+			t.Fatalf("workers %d: got %d results for %d addresses", workers, len(results), len(ips))
+		}
+		for i, r := range results {
+			if err := checkresult(db, i, ips[i], r); err != nil {
+				t.Fatalf("workers %d: %v", workers, err)
+			}
+		}
+		if results[0].Err != ip2location.ErrInvalidAddress {
+			t.Errorf("workers %d: error for %q = %v, want ErrInvalidAddress", workers, ips[0], results[0].Err)
+		}
+	}
+
+	results, err := db.LookupBatch(context.Background(), nil, ip2location.FieldAll, 0)
+	if err != nil || len(results) != 0 {
+		t.Errorf("empty batch = %v, %v", results, err)
+	}
+}
+
+func TestLookupBatchCancel(t *testing.T) {
+	db := opentestdb(t)
+	ips := batchips(200000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond, cancel)
+	results, err := db.LookupBatch(ctx, ips, ip2location.FieldAll, 2)
+	if err == nil {
+		t.Skip("the batch was done before the context was cancelled")
+	}
+	if err != context.Canceled {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if len(results) != len(ips) {
+		t.Fatalf("got %d results for %d addresses", len(results), len(ips))
+	}
+
+	cancelled := 0
+	for i, r := range results {
+		if r.Err == context.Canceled {
+			cancelled++
+			if r.IP != ips[i] {
+				t.Fatalf("result %d is for %q, want %q", i, r.IP, ips[i])
+			}
+			continue
+		}
+		if cancelled > 0 {
+			t.Fatalf("result %d was looked up after result %d was cancelled", i, i-1)
+		}
+		if err := checkresult(db, i, ips[i], r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cancelled == 0 {
+		t.Error("no result has the context error")
+	}
+}
+
+func TestLookupStream(t *testing.T) {
+	db := opentestdb(t)
+	ips := batchips(1000)
+
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, ip := range ips {
+			in <- ip
+		}
+	}()
+
+	i := 0
+	for r := range db.LookupStream(context.Background(), in, ip2location.FieldAll, 4) {
+		if i >= len(ips) {
+			t.Fatalf("more results than the %d addresses", len(ips))
+		}
+		if err := checkresult(db, i, ips[i], r); err != nil {
+			t.Fatal(err)
+		}
+		i++
+	}
+	if i != len(ips) {
+		t.Errorf("got %d results for %d addresses", i, len(ips))
+	}
+}
+
+func TestLookupStreamCancel(t *testing.T) {
+	db := opentestdb(t)
+	ips := batchips(1000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan string) // never closed, the stream ends with ctx
+	go func() {
+		for _, ip := range ips {
+			select {
+			case in <- ip:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	out := db.LookupStream(ctx, in, ip2location.FieldAll, 4)
+	for i := 0; i < 10; i++ {
+		if err := checkresult(db, i, ips[i], <-out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 10; ; i++ {
+			r, ok := <-out
+			if !ok {
+				return
+			}
+			if err := checkresult(db, i, ips[i], r); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the result channel was not closed after the context was cancelled")
+	}
+}
+
+// run with -race: batches, streams and single lookups sharing a DB
+func TestLookupBatchConcurrent(t *testing.T) {
+	db := opentestdb(t)
+	ips := batchips(500)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			results, err := db.LookupBatch(context.Background(), ips, ip2location.FieldAll, 3)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for i, r := range results {
+				if err := checkresult(db, i, ips[i], r); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			in := make(chan string)
+			go func() {
+				defer close(in)
+				for _, ip := range ips {
+					in <- ip
+				}
+			}()
+			i := 0
+			for r := range db.LookupStream(context.Background(), in, ip2location.FieldCity|ip2location.FieldASN, 3) {
+				if r.IP != ips[i] {
+					t.Errorf("stream result %d is for %q, want %q", i, r.IP, ips[i])
+					return
+				}
+				i++
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for _, ip := range ips {
+				_, _ = db.Get_all(ip)
+			}
+		}()
+	}
+	wg.Wait()
+}
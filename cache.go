@@ -0,0 +1,142 @@
+package ip2location
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+
+	"lukechampine.com/uint128"
+)
+
+// The CacheStats struct stores the counters of the range cache of a DB.
+type CacheStats struct {
+	Hits    uint64 // lookups answered from the cache
+	Misses  uint64 // lookups that had to search the BIN database
+	Entries int    // ranges in the cache
+	Size    int    // maximum number of ranges in the cache
+}
+
+// a cached range with all the fields the BIN supports
+type cacheentry struct {
+	match rowmatch
+	rec   IP2Locationrecord
+	elem  *list.Element
+}
+
+// rangecache keeps the most recently used ranges of a DB sorted by IP From so that any IP address
+// in a cached range is found without searching the BIN database.
+type rangecache struct {
+	mu     sync.Mutex
+	size   int
+	ipv4   []*cacheentry
+	ipv6   []*cacheentry
+	lru    *list.List // most recently used first
+	hits   uint64
+	misses uint64
+}
+
+func newrangecache(size int) *rangecache {
+	return &rangecache{size: size, lru: list.New()}
+}
+
+func (c *rangecache) ranges(iptype uint32) *[]*cacheentry {
+	if iptype == 4 {
+		return &c.ipv4
+	}
+	return &c.ipv6
+}
+
+// position of the first cached range starting after the IP number
+func searchranges(ranges []*cacheentry, ipno uint128.Uint128) int {
+	return sort.Search(len(ranges), func(i int) bool { return ranges[i].match.ipfrom.Cmp(ipno) > 0 })
+}
+
+// copies the cached range containing the IP number into m and x, returning false on a miss
+func (c *rangecache) get(iptype uint32, ipno uint128.Uint128, mode Field, x *IP2Locationrecord, m *rowmatch) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ranges := *c.ranges(iptype)
+	i := searchranges(ranges, ipno) - 1
+	if i < 0 || ipno.Cmp(ranges[i].match.ipto) >= 0 {
+		c.misses++
+		return false
+	}
+	c.hits++
+
+	e := ranges[i]
+	c.lru.MoveToFront(e.elem)
+	if mode&e.rec.Fields == e.rec.Fields {
+		*x = e.rec
+	} else {
+		copyfields(x, &e.rec, mode&e.rec.Fields)
+	}
+	if m != nil {
+		*m = e.match
+	}
+	return true
+}
+
+// adds a range, evicting the least recently used one if the cache is full
+func (c *rangecache) put(m rowmatch, rec IP2Locationrecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ranges := c.ranges(m.iptype)
+	i := searchranges(*ranges, m.ipfrom)
+	if i > 0 && (*ranges)[i-1].match.ipfrom.Equals(m.ipfrom) {
+		return // added by a concurrent lookup
+	}
+
+	e := &cacheentry{match: m, rec: rec}
+	e.elem = c.lru.PushFront(e)
+	*ranges = append(*ranges, nil)
+	copy((*ranges)[i+1:], (*ranges)[i:])
+	(*ranges)[i] = e
+
+	if c.lru.Len() > c.size {
+		old := c.lru.Remove(c.lru.Back()).(*cacheentry)
+		ranges = c.ranges(old.match.iptype)
+		j := searchranges(*ranges, old.match.ipfrom) - 1
+		*ranges = append((*ranges)[:j], (*ranges)[j+1:]...)
+	}
+}
+
+// drops every range
+func (c *rangecache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ipv4 = nil
+	c.ipv6 = nil
+	c.lru.Init()
+}
+
+func (c *rangecache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.lru.Len(), Size: c.size}
+}
+
+// EnableCache keeps up to size of the most recently matched IP ranges in memory with all their fields decoded,
+// so that lookups of any IP address in a cached range skip the search of the BIN database. Traffic is often
+// concentrated on few ranges, making a small cache effective. A size of zero or less disables the cache.
+// EnableCache drops the ranges already cached and must be called before the DB is used for lookups.
+// The cache is dropped when the DB is closed. With a ReloadableDB, enable the cache in the function that
+// opens the BIN file so that every reloaded DB gets its own cache.
+func (d *DB) EnableCache(size int) {
+	if size <= 0 {
+		d.cache = nil
+		return
+	}
+	d.cache = newrangecache(size)
+}
+
+// CacheStats returns the counters of the range cache, all zero if it is disabled.
+func (d *DB) CacheStats() CacheStats {
+	if d.cache == nil {
+		return CacheStats{}
+	}
+	return d.cache.stats()
+}
@@ -0,0 +1,126 @@
+package ip2location_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// addresses at the edges of the test ranges and of the gaps between them, and mapped to them
+var cacheips = []string{
+	"0.0.0.0", "8.8.7.255", "8.8.8.0", "8.8.8.8", "8.8.8.255", "8.8.9.0", "10.0.0.0", "10.128.0.1",
+	"10.255.255.255", "11.0.0.0", "9.9.9.9", "255.255.255.255", "::ffff:8.8.8.8", "2002:808:808::1",
+	"::", "2001:db7:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db8::", "2001:db8::1", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff",
+	"2001:db9::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+}
+
+var cachefields = []ip2location.Field{
+	ip2location.FieldAll, ip2location.FieldCountryShort, ip2location.FieldCity | ip2location.FieldASN | ip2location.FieldLatitude,
+	ip2location.FieldElevation,
+}
+
+// checks that the cached DB gives the results of the uncached one
+func comparecached(db *ip2location.DB, cached *ip2location.DB, ip string, fields ip2location.Field) error {
+	want, wanterr := db.Lookup(ip, fields)
+	got, err := cached.Lookup(ip, fields)
+	if err != wanterr || !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("Lookup(%s, %v) = %+v, %v, want %+v, %v", ip, fields, got, err, want, wanterr)
+	}
+	return nil
+}
+
+func TestCacheMatchesUncached(t *testing.T) {
+	for name, open := range map[string]func(t *testing.T) *ip2location.DB{
+		"test ranges":  func(t *testing.T) *ip2location.DB { return opentestdb(t) },
+		"no elevation": noelevationdb,
+	} {
+		db := open(t)
+		cached := open(t)
+		cached.EnableCache(64)
+
+		// the first lookup of a range misses, the others hit
+		for _, pass := range []string{"first", "second"} {
+			for _, fields := range cachefields {
+				for _, ip := range cacheips {
+					if err := comparecached(db, cached, ip, fields); err != nil {
+						t.Errorf("%s, %s pass: %v", name, pass, err)
+					}
+				}
+			}
+		}
+		if s := cached.CacheStats(); s.Hits == 0 || s.Misses == 0 {
+			t.Errorf("%s: CacheStats() = %+v, want both hits and misses", name, s)
+		}
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	db := opentestdb(t)
+	if s := db.CacheStats(); s != (ip2location.CacheStats{}) {
+		t.Errorf("CacheStats() without a cache = %+v", s)
+	}
+
+	db.EnableCache(2)
+	for _, ip := range []string{
+		"8.8.8.8",     // miss
+		"8.8.8.9",     // hit in the same range
+		"10.0.0.1",    // miss
+		"2001:db8::1", // miss, evicting 8.8.8.0/24
+		"8.8.8.8",     // miss, evicting 10.0.0.0/8
+		"2001:db8::2", // hit
+		"10.1.0.0",    // miss
+	} {
+		if _, err := db.Get_all(ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := db.CacheStats(); s != (ip2location.CacheStats{Hits: 2, Misses: 5, Entries: 2, Size: 2}) {
+		t.Errorf("CacheStats() = %+v, want 2 hits, 5 misses and 2 entries", s)
+	}
+
+	db.Close()
+	if s := db.CacheStats(); s.Entries != 0 {
+		t.Errorf("CacheStats() after Close = %+v, want no entries", s)
+	}
+
+	db = opentestdb(t)
+	db.EnableCache(10)
+	db.EnableCache(0)
+	if _, err := db.Get_all("8.8.8.8"); err != nil {
+		t.Fatal(err)
+	}
+	if s := db.CacheStats(); s != (ip2location.CacheStats{}) {
+		t.Errorf("CacheStats() with the cache disabled = %+v", s)
+	}
+}
+
+// run with -race: lookups sharing a small cache, so that ranges are added and evicted concurrently
+func TestCacheConcurrent(t *testing.T) {
+	db := opentestdb(t)
+	cached := opentestdb(t)
+	cached.EnableCache(3)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				ip := cacheips[(g+i)%len(cacheips)]
+				if err := comparecached(db, cached, ip, cachefields[i%len(cachefields)]); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,145 @@
+package ip2location_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// addresses answered from the IPv4 data, the IPv6 data and through the 6to4 and Teredo ranges
+var concurrentips = []string{"8.8.8.8", "2001:db8::1", "2002:808:808::1", "2001:0:4136:e378:8000:63bf:f7f7:f7f7"}
+
+// run with -race: DBs opened, queried and closed from many goroutines at once
+func TestConcurrentOpenDBAndClose(t *testing.T) {
+	data := testbin(t)
+	path := writebin(t, data)
+	want := make(map[string]ip2location.IP2Locationrecord)
+	for _, ip := range concurrentips {
+		want[ip], _ = opentestdb(t).Get_all(ip)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				var db *ip2location.DB
+				var err error
+				if (g+i)%2 == 0 {
+					db, err = ip2location.OpenDB(path)
+				} else {
+					db, err = ip2location.OpenDBWithBytes(data)
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				for _, ip := range concurrentips {
+					if x, err := db.Get_all(ip); err != nil || x != want[ip] {
+						t.Errorf("Get_all(%s) = %+v, %v, want %+v", ip, x, err, want[ip])
+					}
+				}
+				db.Close()
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+// run with -race: the deprecated functions sharing the default DB while it is opened and closed
+func TestConcurrentDeprecatedAPI(t *testing.T) {
+	path := writebin(t, testbin(t))
+	defer ip2location.Close()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				ip2location.Open(path)
+				if i%3 == 0 {
+					ip2location.Close()
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				// either the default DB is open and answers, or it is closed and answers nothing
+				if x := ip2location.Get_all("8.8.8.8"); x.Country_short != "" && x.Country_short != googlerec.Country_short {
+					t.Errorf("Get_all(8.8.8.8) = %+v", x)
+					return
+				}
+				_ = ip2location.Get_country_short("2001:db8::1")
+			}
+		}()
+	}
+	wg.Wait()
+
+	ip2location.Open(path)
+	if x := ip2location.Get_all("8.8.8.8"); x.Country_short != googlerec.Country_short {
+		t.Errorf("Get_all(8.8.8.8) after Open = %+v", x)
+	}
+}
+
+// run with -race: lookups on a ReloadableDB while it is reloaded
+func TestConcurrentReload(t *testing.T) {
+	r, err := ip2location.OpenReloadableDB(writebin(t, testbin(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var reloads int
+	var mu sync.Mutex
+	r.OnReload(func(db *ip2location.DB) {
+		mu.Lock()
+		reloads++
+		mu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if err := r.Reload(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				x, err := r.Get_all("8.8.8.8")
+				if err != nil || x.City != googlerec.City {
+					errs <- fmt.Errorf("Get_all(8.8.8.8) during Reload = %+v, %v", x, err)
+					return
+				}
+				if v := r.DatabaseVersion(); v == "" {
+					errs <- fmt.Errorf("DatabaseVersion during Reload is empty")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if reloads != 40 {
+		t.Errorf("OnReload called %d times, want 40", reloads)
+	}
+
+	r.Close()
+	if _, err := r.Get_all("8.8.8.8"); err == nil {
+		t.Error("Get_all after Close succeeded")
+	}
+}
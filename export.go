@@ -0,0 +1,184 @@
+package ip2location
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The CSVExportOptions struct controls how ExportCSV writes the BIN database.
+type CSVExportOptions struct {
+	// Fields selects the columns to write after ip_from and ip_to. The columns keep the order of the
+	// IP2Location CSV for the database type. Zero writes every column the BIN database has.
+	Fields Field
+
+	// IPNotation writes the IP addresses in dotted (IPv4) or colon (IPv6) notation
+	// instead of decimal IP numbers.
+	IPNotation bool
+
+	// IPv4Only and IPv6Only restrict the export to the IPv4 or the IPv6 ranges.
+	IPv4Only bool
+	IPv6Only bool
+
+	// Header writes a first line with the column names. The IP2Location CSV files have no header.
+	Header bool
+
+	// Gzip compresses the output.
+	Gzip bool
+}
+
+// a CSV column after ip_from and ip_to
+type csvcolumn struct {
+	name  string
+	field Field
+	value func(x *IP2Locationrecord) string
+}
+
+var csvcolumns = []csvcolumn{
+	{"country_code", FieldCountryShort, func(x *IP2Locationrecord) string { return x.Country_short }},
+	{"country_name", FieldCountryLong, func(x *IP2Locationrecord) string { return x.Country_long }},
+	{"region_name", FieldRegion, func(x *IP2Locationrecord) string { return x.Region }},
+	{"city_name", FieldCity, func(x *IP2Locationrecord) string { return x.City }},
+	{"isp", FieldISP, func(x *IP2Locationrecord) string { return x.Isp }},
+	{"latitude", FieldLatitude, func(x *IP2Locationrecord) string { return csvcoordinate(x.Latitude) }},
+	{"longitude", FieldLongitude, func(x *IP2Locationrecord) string { return csvcoordinate(x.Longitude) }},
+	{"domain", FieldDomain, func(x *IP2Locationrecord) string { return x.Domain }},
+	{"zip_code", FieldZipCode, func(x *IP2Locationrecord) string { return x.Zipcode }},
+	{"time_zone", FieldTimeZone, func(x *IP2Locationrecord) string { return x.Timezone }},
+	{"net_speed", FieldNetSpeed, func(x *IP2Locationrecord) string { return x.Netspeed }},
+	{"idd_code", FieldIDDCode, func(x *IP2Locationrecord) string { return x.Iddcode }},
+	{"area_code", FieldAreaCode, func(x *IP2Locationrecord) string { return x.Areacode }},
+	{"weather_station_code", FieldWeatherStationCode, func(x *IP2Locationrecord) string { return x.Weatherstationcode }},
+	{"weather_station_name", FieldWeatherStationName, func(x *IP2Locationrecord) string { return x.Weatherstationname }},
+	{"mcc", FieldMCC, func(x *IP2Locationrecord) string { return x.Mcc }},
+	{"mnc", FieldMNC, func(x *IP2Locationrecord) string { return x.Mnc }},
+	{"mobile_brand", FieldMobileBrand, func(x *IP2Locationrecord) string { return x.Mobilebrand }},
+	{"elevation", FieldElevation, func(x *IP2Locationrecord) string { return strconv.FormatFloat(float64(x.Elevation), 'f', -1, 32) }},
+	{"usage_type", FieldUsageType, func(x *IP2Locationrecord) string { return x.Usagetype }},
+	{"address_type", FieldAddressType, func(x *IP2Locationrecord) string { return x.Addresstype }},
+	{"category", FieldCategory, func(x *IP2Locationrecord) string { return x.Category }},
+	{"district", FieldDistrict, func(x *IP2Locationrecord) string { return x.District }},
+	{"asn", FieldASN, func(x *IP2Locationrecord) string { return x.Asn }},
+	{"as", FieldAS, func(x *IP2Locationrecord) string { return x.As }},
+	{"as_domain", FieldASDomain, func(x *IP2Locationrecord) string { return x.Asdomain }},
+	{"as_usage_type", FieldASUsageType, func(x *IP2Locationrecord) string { return x.Asusagetype }},
+	{"as_cidr", FieldASCIDR, func(x *IP2Locationrecord) string { return x.Ascidr }},
+}
+
+// returns the CSV columns of the schema for the selected fields, in the order of the IP2Location CSV
+func typecsvcolumns(schema []Column, fields Field) []csvcolumn {
+	if fields == 0 {
+		fields = FieldAll
+	}
+
+	var cols []csvcolumn
+	for _, c := range csvcolumns {
+		if fields&c.field != 0 && columnposition(schema, c.field) != 0 {
+			cols = append(cols, c)
+		}
+	}
+	sort.SliceStable(cols, func(i, j int) bool {
+		return columnposition(schema, cols[i].field) < columnposition(schema, cols[j].field)
+	})
+	return cols
+}
+
+// format a coordinate with 6 decimals as in the IP2Location CSV files, rounding the shortest
+// float32 representation so that the digits lost when the BIN was built are not made up
+func csvcoordinate(v float32) string {
+	return strconv.FormatFloat(float32to64(v), 'f', 6, 64)
+}
+
+// quote a CSV value the way the IP2Location CSV files do
+func csvquote(s string) string {
+	return "\"" + strings.ReplaceAll(s, "\"", "\"\"") + "\""
+}
+
+// ExportCSV writes the IPv4 and IPv6 ranges of the BIN database to w in the IP2Location CSV layout
+// for its database type, with the IP addresses written as decimal IP numbers unless opts.IPNotation is set.
+func (d *DB) ExportCSV(w io.Writer, opts CSVExportOptions) error {
+	cols := typecsvcolumns(d.columns, opts.Fields)
+	var fields Field
+	for _, c := range cols {
+		fields |= c.field
+	}
+
+	var it *RangeIterator
+	switch {
+	case opts.IPv4Only:
+		it = d.RangesIPv4(fields)
+	case opts.IPv6Only:
+		it = d.RangesIPv6(fields)
+	default:
+		it = d.Ranges(fields)
+	}
+
+	var gz *gzip.Writer
+	if opts.Gzip {
+		gz = gzip.NewWriter(w)
+		w = gz
+	}
+	bw := bufio.NewWriter(w)
+
+	line := make([]string, 0, len(cols)+2)
+	if opts.Header {
+		line = append(line, csvquote("ip_from"), csvquote("ip_to"))
+		for _, c := range cols {
+			line = append(line, csvquote(c.name))
+		}
+		if _, err := bw.WriteString(strings.Join(line, ",") + "\r\n"); err != nil {
+			return err
+		}
+	}
+
+	for it.Next() {
+		r := it.Range()
+		line = line[:0]
+		if opts.IPNotation {
+			line = append(line, csvquote(r.IPFrom.String()), csvquote(r.IPTo.String()))
+		} else {
+			_, from := addrtonum(r.IPFrom)
+			_, to := addrtonum(r.IPTo)
+			line = append(line, csvquote(from.String()), csvquote(to.String()))
+		}
+		for _, c := range cols {
+			if c.field == FieldElevation && r.Record.Fields&FieldElevation == 0 {
+				line = append(line, csvquote("-")) // as read from the BIN
+				continue
+			}
+			line = append(line, csvquote(c.value(&r.Record)))
+		}
+		if _, err := bw.WriteString(strings.Join(line, ",") + "\r\n"); err != nil {
+			return err
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
+// ExportCSVFile is like ExportCSV but writes to the file at the given path.
+func (d *DB) ExportCSVFile(csvpath string, opts CSVExportOptions) error {
+	f, err := os.Create(csvpath)
+	if err != nil {
+		return err
+	}
+
+	err = d.ExportCSV(f, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,9 @@
+package ip2location
+
+// UnregisterSchema removes a schema registered by a test, so that the registry is left as the test found it.
+func UnregisterSchema(databasetype uint8) {
+	schemamu.Lock()
+	defer schemamu.Unlock()
+
+	delete(schemas, databasetype)
+}
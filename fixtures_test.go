@@ -0,0 +1,71 @@
+package ip2location_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+	"github.com/ip2location/ip2location-go/v9/ip2locationtest"
+)
+
+// records of the test databases
+var (
+	googlerec = ip2location.IP2Locationrecord{
+		Country_short: "US", Country_long: "United States of America", Region: "California", City: "Mountain View",
+		Isp: "Google LLC", Latitude: 37.40599, Longitude: -122.078514, Domain: "google.com", Zipcode: "94043",
+		Timezone: "-07:00", Netspeed: "T1", Iddcode: "1", Areacode: "650", Weatherstationcode: "USCA0746",
+		Weatherstationname: "Mountain View", Mcc: "-", Mnc: "-", Mobilebrand: "-", Elevation: 32, Usagetype: "DCH",
+		Addresstype: "A", Category: "IAB19-11", District: "Santa Clara County", Asn: "15169", As: "Google LLC",
+		Asdomain: "google.com", Asusagetype: "DCH", Ascidr: "8.8.8.0/24",
+	}
+	privaterec = ip2location.IP2Locationrecord{
+		Country_short: "JP", Country_long: "Japan", Region: "Tokyo", City: "Tokyo", Latitude: 35.6895, Longitude: 139.69171,
+		Timezone: "+09:00", Netspeed: "DSL", Elevation: 40, Addresstype: "U", Asn: "2497", Ascidr: "10.0.0.0/8",
+	}
+	ipv6rec = ip2location.IP2Locationrecord{
+		Country_short: "DE", Country_long: "Germany", Region: "Hessen", City: "Frankfurt am Main",
+		Timezone: "+01:00", Asn: "3320", Ascidr: "2001:db8::/32",
+	}
+)
+
+// the ranges of the test databases
+var testranges = []ip2locationtest.Range{
+	{From: "8.8.8.0", To: "8.8.8.255", Record: googlerec},
+	{From: "10.0.0.0", To: "10.255.255.255", Record: privaterec},
+	{From: "2001:db8::", To: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", Record: ipv6rec},
+}
+
+// returns a DB26 BIN database holding the test ranges
+func testbin(tb testing.TB) []byte {
+	tb.Helper()
+
+	data, err := ip2locationtest.Build(26, testranges...)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+// writes the BIN database to a temporary directory and returns its path
+func writebin(tb testing.TB, data []byte) string {
+	tb.Helper()
+
+	path := filepath.Join(tb.TempDir(), "IP2LOCATION.BIN")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
+
+// opens the test database from memory
+func opentestdb(tb testing.TB) *ip2location.DB {
+	tb.Helper()
+
+	db, err := ip2location.OpenDBWithBytes(testbin(tb))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(db.Close)
+	return db
+}
@@ -0,0 +1,124 @@
+package ip2location_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+	"github.com/ip2location/ip2location-go/v9/ip2locationtest"
+)
+
+// a DBReader over a byte slice
+type bytesreader struct {
+	*bytes.Reader
+}
+
+func (bytesreader) Close() error { return nil }
+
+// returns BIN databases of several types and layouts, with copies whose headers are broken in the ways seen in
+// uploaded files, to seed the fuzz targets
+func fuzzseeds(f *testing.F) [][]byte {
+	var seeds [][]byte
+	for _, dbt := range []int{1, 3, 5, 11, 19, 24, 26} {
+		data, err := ip2locationtest.Build(dbt, testranges...)
+		if err != nil {
+			f.Fatal(err)
+		}
+		seeds = append(seeds, data)
+	}
+	db26 := seeds[len(seeds)-1]
+	ipv4only, err := ip2locationtest.Build(3, testranges[:2]...)
+	if err != nil {
+		f.Fatal(err)
+	}
+	seeds = append(seeds, ipv4only)
+
+	header := func(off int, v uint32) []byte {
+		data := append([]byte(nil), db26...)
+		if off < 5 {
+			data[off] = byte(v)
+		} else {
+			binary.LittleEndian.PutUint32(data[off:], v)
+		}
+		return data
+	}
+	seeds = append(seeds,
+		header(0, 27),         // unknown type
+		header(0, 255),        // unknown type
+		header(1, 0),          // no columns
+		header(1, 1),          // too few columns
+		header(5, 0xffffffff), // IPv4 rows past the end
+		header(9, 0xfffffff0), // IPv4 data past the end
+		header(13, 0),         // no IPv6 rows
+		header(21, 0xffffff),  // IPv4 index past the end
+		header(25, 3),         // IPv6 index inside the header
+	)
+	seeds = append(seeds, nil, db26[:63], db26[:64], db26[:len(db26)/2])
+	return seeds
+}
+
+// checks that an error of a lookup is one of the documented errors
+func checklookuperr(t *testing.T, what string, err error) {
+	t.Helper()
+
+	for _, known := range []error{ip2location.ErrInvalidDatabase, ip2location.ErrNotFound, ip2location.ErrInvalidAddress,
+		ip2location.ErrIPv6NotSupported, ip2location.ErrFieldNotSupported} {
+		if errors.Is(err, known) {
+			return
+		}
+	}
+	if err != nil {
+		t.Errorf("%s: unexpected error %v", what, err)
+	}
+}
+
+func FuzzOpenDBWithReader(f *testing.F) {
+	for _, data := range fuzzseeds(f) {
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		db, err := ip2location.OpenDBWithReader(bytesreader{bytes.NewReader(data)})
+		if err != nil {
+			if !errors.Is(err, ip2location.ErrInvalidDatabase) {
+				t.Errorf("OpenDBWithReader error %v is not ErrInvalidDatabase", err)
+			}
+			return
+		}
+		defer db.Close()
+
+		_ = db.Metadata()
+		_ = db.Validate()
+
+		it := db.Ranges(ip2location.FieldAll)
+		for i := 0; i < 100 && it.Next(); i++ {
+			_ = it.Range()
+		}
+		checklookuperr(t, "Ranges", it.Err())
+	})
+}
+
+func FuzzQuery(f *testing.F) {
+	for _, data := range fuzzseeds(f) {
+		for _, ip := range []string{"8.8.8.8", "10.255.255.255", "0.0.0.0", "2001:db8::1", "2002:808:808::", "::ffff:10.0.0.1", "ffff::"} {
+			f.Add(data, ip)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte, ip string) {
+		db, err := ip2location.OpenDBWithBytes(data)
+		if err != nil {
+			return
+		}
+		defer db.Close()
+
+		_, err = db.Get_all(ip)
+		checklookuperr(t, "Get_all", err)
+		_, err = db.Query(ip, ip2location.FieldCountryLong|ip2location.FieldCity|ip2location.FieldASN)
+		checklookuperr(t, "Query", err)
+		var x ip2location.IP2Locationrecord
+		checklookuperr(t, "LookupInto", db.LookupInto(ip, &x))
+	})
+}
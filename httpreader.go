@@ -0,0 +1,298 @@
+package ip2location
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const no_range_support string = "The server does not support HTTP range requests."
+
+// The HTTPRangeReaderOptions struct configures an HTTPRangeReader. Zero values select the defaults.
+type HTTPRangeReaderOptions struct {
+	Client      *http.Client // defaults to http.DefaultClient
+	Header      http.Header  // extra request headers, such as Authorization
+	BlockSize   int          // bytes fetched per request, defaults to 64 KiB
+	CacheBlocks int          // blocks kept besides the header and indexes, defaults to 256
+}
+
+// a block of the remote file, being fetched until done is closed
+type httpblock struct {
+	data []byte
+	err  error
+	done chan struct{}
+	elem *list.Element // position in the LRU list, nil for pinned blocks
+}
+
+// The HTTPRangeReader struct is a DBReader over a BIN file served over HTTP, reading it with Range requests
+// in blocks that are cached in memory. The blocks holding the header and the indexes are kept for good
+// once OpenDBWithReader has read them, other blocks are evicted least recently used first. Later requests
+// carry the ETag or Last-Modified of the first response in If-Range, so that reads fail once the file is
+// replaced instead of mixing blocks of two versions of it.
+//
+//	r, err := ip2location.NewHTTPRangeReader(ctx, "http://store.internal/IP2LOCATION-LITE-DB11.BIN", ip2location.HTTPRangeReaderOptions{})
+//	...
+//	db, err := ip2location.OpenDBWithReader(r)
+//	...
+//	results, err := db.QueryContext(ctx, "8.8.8.8", ip2location.FieldAll)
+type HTTPRangeReader struct {
+	url       string
+	client    *http.Client
+	header    http.Header
+	blocksize int64
+	maxblocks int
+	size      int64
+	validator string // strong ETag or Last-Modified of the file, empty if the server sends neither
+
+	mu     sync.Mutex // guards the fields below
+	blocks map[int64]*httpblock
+	lru    *list.List
+	pinned [][2]int64 // byte ranges whose blocks are never evicted
+	offset int64      // position for Read
+}
+
+// NewHTTPRangeReader returns a reader over the file at the URL. It fetches the first block to learn the
+// size of the file and fails if the server does not answer Range requests with partial content.
+func NewHTTPRangeReader(ctx context.Context, url string, opts HTTPRangeReaderOptions) (*HTTPRangeReader, error) {
+	r := &HTTPRangeReader{
+		url:       url,
+		client:    opts.Client,
+		header:    opts.Header,
+		blocksize: int64(opts.BlockSize),
+		maxblocks: opts.CacheBlocks,
+		size:      -1,
+		blocks:    make(map[int64]*httpblock),
+		lru:       list.New(),
+		pinned:    [][2]int64{{0, 64}}, // header
+	}
+	if r.client == nil {
+		r.client = http.DefaultClient
+	}
+	if r.blocksize <= 0 {
+		r.blocksize = 64 << 10
+	}
+	if r.maxblocks <= 0 {
+		r.maxblocks = 256
+	}
+
+	data, size, validator, err := r.fetch(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	r.size = size
+	r.validator = validator
+	done := make(chan struct{})
+	close(done)
+	r.blocks[0] = &httpblock{data: data, done: done}
+	return r, nil
+}
+
+// Size returns the size of the remote file.
+func (r *HTTPRangeReader) Size() int64 {
+	return r.size
+}
+
+// returns the validator of the response that If-Range accepts: a strong ETag, or else the Last-Modified date
+func rangevalidator(h http.Header) string {
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return h.Get("Last-Modified")
+}
+
+// fetch requests a block, returning its data, the size of the whole file and its validator
+func (r *HTTPRangeReader) fetch(ctx context.Context, n int64) ([]byte, int64, string, error) {
+	start := n * r.blocksize
+	end := start + r.blocksize - 1
+	if r.size >= 0 && end >= r.size {
+		end = r.size - 1
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(start, 10)+"-"+strconv.FormatInt(end, 10))
+	if r.validator != "" {
+		req.Header.Set("If-Range", r.validator) // the whole file is sent instead if it was replaced
+	}
+
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer res.Body.Close()
+
+	// blocks of two versions of the file must not be mixed
+	validator := rangevalidator(res.Header)
+	if r.size >= 0 && (res.StatusCode == http.StatusOK || validator != r.validator) {
+		return nil, 0, "", fmt.Errorf("%s changed since it was opened", r.url)
+	}
+	if res.StatusCode == http.StatusOK {
+		return nil, 0, "", errors.New(no_range_support)
+	}
+	if res.StatusCode != http.StatusPartialContent {
+		return nil, 0, "", fmt.Errorf("unexpected HTTP status %s for %s", res.Status, r.url)
+	}
+
+	// Content-Range: bytes start-end/size
+	var first, last, size int64
+	cr := res.Header.Get("Content-Range")
+	i := strings.IndexByte(cr, '/')
+	if i < 0 || !strings.HasPrefix(cr, "bytes ") {
+		return nil, 0, "", errors.New(no_range_support)
+	}
+	if size, err = strconv.ParseInt(cr[i+1:], 10, 64); err != nil {
+		return nil, 0, "", errors.New(no_range_support)
+	}
+	if r.size >= 0 && size != r.size {
+		return nil, 0, "", fmt.Errorf("the size of %s changed from %d to %d bytes", r.url, r.size, size)
+	}
+	if end >= size {
+		end = size - 1 // first block of a file smaller than a block
+	}
+	if _, err = fmt.Sscanf(cr[len("bytes "):i], "%d-%d", &first, &last); err != nil || first != start || last != end || last < first {
+		return nil, 0, "", fmt.Errorf("unexpected Content-Range %q for %s", cr, r.url)
+	}
+
+	data := make([]byte, last-first+1)
+	if _, err = io.ReadFull(res.Body, data); err != nil {
+		return nil, 0, "", err
+	}
+	return data, size, validator, nil
+}
+
+// pin keeps the blocks of the byte range once they are fetched, called by OpenDBWithReader for the indexes
+func (r *HTTPRangeReader) pin(off int64, n int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pinned = append(r.pinned, [2]int64{off, off + n})
+}
+
+func (r *HTTPRangeReader) ispinned(n int64) bool {
+	start, end := n*r.blocksize, (n+1)*r.blocksize
+	for _, p := range r.pinned {
+		if start < p[1] && p[0] < end {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the data of a block, fetching it if it is not cached
+func (r *HTTPRangeReader) block(ctx context.Context, n int64) ([]byte, error) {
+	for {
+		r.mu.Lock()
+		b, ok := r.blocks[n]
+		if ok {
+			if b.elem != nil {
+				r.lru.MoveToFront(b.elem)
+			}
+			r.mu.Unlock()
+
+			select {
+			case <-b.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if b.err == nil {
+				return b.data, nil
+			}
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			continue // the lookup fetching the block failed or was cancelled, try again
+		}
+
+		b = &httpblock{done: make(chan struct{})}
+		r.blocks[n] = b
+		if !r.ispinned(n) {
+			b.elem = r.lru.PushFront(n)
+			if r.lru.Len() > r.maxblocks {
+				delete(r.blocks, r.lru.Remove(r.lru.Back()).(int64))
+			}
+		}
+		r.mu.Unlock()
+
+		b.data, _, _, b.err = r.fetch(ctx, n)
+		if b.err != nil {
+			r.mu.Lock()
+			if r.blocks[n] == b {
+				delete(r.blocks, n)
+				if b.elem != nil {
+					r.lru.Remove(b.elem)
+				}
+			}
+			r.mu.Unlock()
+		}
+		close(b.done)
+		if b.err != nil {
+			return nil, b.err
+		}
+		return b.data, nil
+	}
+}
+
+// ReadAtContext is like ReadAt but stops waiting for the server when ctx is done.
+func (r *HTTPRangeReader) ReadAtContext(ctx context.Context, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for n < len(p) && off < r.size {
+		data, err := r.block(ctx, off/r.blocksize)
+		if err != nil {
+			return n, err
+		}
+		c := copy(p[n:], data[off%r.blocksize:])
+		n += c
+		off += int64(c)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// ReadAt reads len(p) bytes of the remote file starting at off.
+func (r *HTTPRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	return r.ReadAtContext(context.Background(), p, off)
+}
+
+// Read reads the remote file sequentially from the start.
+func (r *HTTPRangeReader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	off := r.offset
+	r.mu.Unlock()
+
+	n, err := r.ReadAt(p, off)
+
+	r.mu.Lock()
+	r.offset = off + int64(n)
+	r.mu.Unlock()
+	return n, err
+}
+
+// Close drops the cached blocks.
+func (r *HTTPRangeReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.blocks = make(map[int64]*httpblock)
+	r.lru.Init()
+	return nil
+}
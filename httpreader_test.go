@@ -0,0 +1,175 @@
+package ip2location_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// serves the file with Range support, counting the requests
+func rangeserver(t *testing.T, data []byte, requests *int64) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt64(requests, 1)
+		http.ServeContent(w, req, "IP2LOCATION.BIN", time.Time{}, bytes.NewReader(data))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// parses the Range header of a block request
+func requestedrange(t *testing.T, req *http.Request) (int64, int64) {
+	var first, last int64
+	if _, err := fmt.Sscanf(req.Header.Get("Range"), "bytes=%d-%d", &first, &last); err != nil {
+		t.Errorf("bad Range header %q", req.Header.Get("Range"))
+	}
+	return first, last
+}
+
+func TestHTTPRangeReader(t *testing.T) {
+	data := testbin(t)
+	var requests int64
+	srv := rangeserver(t, data, &requests)
+
+	ctx := context.Background()
+	r, err := ip2location.NewHTTPRangeReader(ctx, srv.URL, ip2location.HTTPRangeReaderOptions{BlockSize: 4096, CacheBlocks: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size() != int64(len(data)) {
+		t.Errorf("Size() = %d, want %d", r.Size(), len(data))
+	}
+	db, err := ip2location.OpenDBWithReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	local := opentestdb(t)
+	for _, ip := range []string{"8.8.8.8", "10.1.2.3", "2001:db8::1", "192.0.2.1"} {
+		want, _ := local.Get_all(ip)
+		got, err := db.QueryContext(ctx, ip, ip2location.FieldAll)
+		if err != nil || got != want {
+			t.Errorf("QueryContext(%s) = %+v, %v, want %+v", ip, got, err, want)
+		}
+	}
+
+	before := atomic.LoadInt64(&requests)
+	if _, err := db.Get_all("8.8.8.8"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt64(&requests) - before; n != 0 {
+		t.Errorf("a lookup of cached blocks made %d requests", n)
+	}
+
+	if r := db.Validate(); !r.OK() {
+		t.Errorf("Validate over HTTP: %s", r)
+	}
+}
+
+func TestHTTPRangeReaderNoRangeSupport(t *testing.T) {
+	data := testbin(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	if _, err := ip2location.NewHTTPRangeReader(context.Background(), srv.URL, ip2location.HTTPRangeReaderOptions{}); err == nil {
+		t.Error("NewHTTPRangeReader succeeded on a server without Range support")
+	}
+}
+
+func TestHTTPRangeReaderShortResponse(t *testing.T) {
+	data := testbin(t)
+	size := strconv.Itoa(len(data))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		first, last := requestedrange(t, req)
+		if first > 0 {
+			last = first + 10 // fewer bytes than asked for
+		}
+		if last >= int64(len(data)) {
+			last = int64(len(data)) - 1
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%s", first, last, size))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(data[first : last+1])
+	}))
+	defer srv.Close()
+
+	r, err := ip2location.NewHTTPRangeReader(context.Background(), srv.URL, ip2location.HTTPRangeReaderOptions{BlockSize: 128})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := make([]byte, 200)
+	if _, err := r.ReadAt(p, 128+100); err == nil || !strings.Contains(err.Error(), "Content-Range") {
+		t.Errorf("ReadAt of a short block = %v, want a Content-Range error", err)
+	}
+}
+
+func TestHTTPRangeReaderFileReplaced(t *testing.T) {
+	data := testbin(t)
+	var replaced int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		content := data
+		if atomic.LoadInt32(&replaced) == 1 {
+			content = append(append([]byte(nil), data...), make([]byte, 1000)...)
+		}
+		http.ServeContent(w, req, "IP2LOCATION.BIN", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	r, err := ip2location.NewHTTPRangeReader(context.Background(), srv.URL, ip2location.HTTPRangeReaderOptions{BlockSize: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&replaced, 1)
+
+	p := make([]byte, 100)
+	if _, err := r.ReadAt(p, 8192); err == nil || !strings.Contains(err.Error(), "size") {
+		t.Errorf("ReadAt after the file changed size = %v, want a size error", err)
+	}
+}
+
+func TestHTTPRangeReaderFileReplacedSameSize(t *testing.T) {
+	data := testbin(t)
+	newer := append([]byte(nil), data...)
+	newer[len(newer)-1] ^= 0xff
+	for _, validator := range []string{"ETag", "Last-Modified"} {
+		var replaced int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			content, etag, modtime := data, `"v1"`, january
+			if atomic.LoadInt32(&replaced) == 1 {
+				content, etag, modtime = newer, `"v2"`, february
+			}
+			if validator == "ETag" {
+				w.Header().Set("ETag", etag)
+				modtime = time.Time{}
+			}
+			http.ServeContent(w, req, "IP2LOCATION.BIN", modtime, bytes.NewReader(content))
+		}))
+
+		r, err := ip2location.NewHTTPRangeReader(context.Background(), srv.URL, ip2location.HTTPRangeReaderOptions{BlockSize: 4096})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := make([]byte, 100)
+		if _, err := r.ReadAt(p, 4096); err != nil {
+			t.Errorf("%s: ReadAt before the file changed: %v", validator, err)
+		}
+		atomic.StoreInt32(&replaced, 1)
+		if _, err := r.ReadAt(p, int64(len(data))-100); err == nil || !strings.Contains(err.Error(), "changed") {
+			t.Errorf("%s: ReadAt after the file was replaced by one of the same size = %v, want an error", validator, err)
+		}
+		srv.Close()
+	}
+}
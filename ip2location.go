@@ -5,17 +5,15 @@
 package ip2location
 
 import (
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"lukechampine.com/uint128"
-	"math"
-	"math/big"
-	"net"
+	"net/netip"
 	"os"
 	"strconv"
+	"sync"
 	"unsafe"
 )
 
@@ -76,196 +74,117 @@ type IP2Locationrecord struct {
 	Asdomain           string
 	Asusagetype        string
 	Ascidr             string
+
+	// Fields lists the fields that were found in the database for this record.
+	// Fields that the BIN does not support are left empty and are not listed, and neither is
+	// FieldElevation when the database has "-" instead of a number for the range.
+	Fields Field
 }
 
 type DB struct {
 	f    DBReader
+	data []byte // whole BIN file when opened in memory or memory-mapped
+	mmap bool   // data is memory-mapped so strings must be copied out of it
 	meta ip2locationmeta
 
-	country_position_offset            uint32
-	region_position_offset             uint32
-	city_position_offset               uint32
-	isp_position_offset                uint32
-	domain_position_offset             uint32
-	zipcode_position_offset            uint32
-	latitude_position_offset           uint32
-	longitude_position_offset          uint32
-	timezone_position_offset           uint32
-	netspeed_position_offset           uint32
-	iddcode_position_offset            uint32
-	areacode_position_offset           uint32
-	weatherstationcode_position_offset uint32
-	weatherstationname_position_offset uint32
-	mcc_position_offset                uint32
-	mnc_position_offset                uint32
-	mobilebrand_position_offset        uint32
-	elevation_position_offset          uint32
-	usagetype_position_offset          uint32
-	addresstype_position_offset        uint32
-	category_position_offset           uint32
-	district_position_offset           uint32
-	asn_position_offset                uint32
-	as_position_offset                 uint32
-	asdomain_position_offset           uint32
-	asusagetype_position_offset        uint32
-	ascidr_position_offset             uint32
-
-	country_enabled            bool
-	region_enabled             bool
-	city_enabled               bool
-	isp_enabled                bool
-	domain_enabled             bool
-	zipcode_enabled            bool
-	latitude_enabled           bool
-	longitude_enabled          bool
-	timezone_enabled           bool
-	netspeed_enabled           bool
-	iddcode_enabled            bool
-	areacode_enabled           bool
-	weatherstationcode_enabled bool
-	weatherstationname_enabled bool
-	mcc_enabled                bool
-	mnc_enabled                bool
-	mobilebrand_enabled        bool
-	elevation_enabled          bool
-	usagetype_enabled          bool
-	addresstype_enabled        bool
-	category_enabled           bool
-	district_enabled           bool
-	asn_enabled                bool
-	as_enabled                 bool
-	asdomain_enabled           bool
-	asusagetype_enabled        bool
-	ascidr_enabled             bool
+	columns []Column       // columns read from the rows
+	readers []columnreader // decoders of the columns
+	fields  Field          // fields supported by the BIN
+
+	cache *rangecache // recently matched ranges, nil if disabled
+
+	mapping MappingPolicy // IPv6 transition addresses looked up in the IPv4 data
 
 	metaok bool
 }
 
 var defaultDB = &DB{}
-
-var country_position = [27]uint8{0, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2}
-var region_position = [27]uint8{0, 0, 0, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3}
-var city_position = [27]uint8{0, 0, 0, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4, 4}
-var isp_position = [27]uint8{0, 0, 3, 0, 5, 0, 7, 5, 7, 0, 8, 0, 9, 0, 9, 0, 9, 0, 9, 7, 9, 0, 9, 7, 9, 9, 9}
-var latitude_position = [27]uint8{0, 0, 0, 0, 0, 5, 5, 0, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5}
-var longitude_position = [27]uint8{0, 0, 0, 0, 0, 6, 6, 0, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6, 6}
-var domain_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 6, 8, 0, 9, 0, 10, 0, 10, 0, 10, 0, 10, 8, 10, 0, 10, 8, 10, 10, 10}
-var zipcode_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 7, 7, 7, 7, 0, 7, 7, 7, 0, 7, 0, 7, 7, 7, 0, 7, 7, 7}
-var timezone_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 8, 7, 8, 8, 8, 7, 8, 0, 8, 8, 8, 0, 8, 8, 8}
-var netspeed_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 8, 11, 0, 11, 8, 11, 0, 11, 0, 11, 0, 11, 11, 11}
-var iddcode_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 12, 0, 12, 0, 12, 9, 12, 0, 12, 12, 12}
-var areacode_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 13, 0, 13, 0, 13, 10, 13, 0, 13, 13, 13}
-var weatherstationcode_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 14, 0, 14, 0, 14, 0, 14, 14, 14}
-var weatherstationname_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 15, 0, 15, 0, 15, 0, 15, 15, 15}
-var mcc_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9, 16, 0, 16, 9, 16, 16, 16}
-var mnc_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 10, 17, 0, 17, 10, 17, 17, 17}
-var mobilebrand_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 11, 18, 0, 18, 11, 18, 18, 18}
-var elevation_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 11, 19, 0, 19, 19, 19}
-var usagetype_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 12, 20, 20, 20}
-var addresstype_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 21, 21}
-var category_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 22, 22}
-var district_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 23}
-var asn_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 24}
-var as_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 25}
-var asdomain_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 26}
-var asusagetype_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 27}
-var ascidr_position = [27]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 28}
+var defaultmu sync.RWMutex // guards defaultDB for the deprecated functions
 
 const api_version string = "9.8.0"
 
 var max_ipv4_range = uint128.From64(4294967295)
-var max_ipv6_range = uint128.From64(0)
+var max_ipv6_range = uint128.Max
 var from_v4mapped = uint128.From64(281470681743360)
 var to_v4mapped = uint128.From64(281474976710655)
-var from_6to4 = uint128.From64(0)
-var to_6to4 = uint128.From64(0)
-var from_teredo = uint128.From64(0)
-var to_teredo = uint128.From64(0)
+var from_6to4 = uint128.New(0, 0x2002000000000000)                  // 2002::
+var to_6to4 = uint128.New(0xffffffffffffffff, 0x2002ffffffffffff)   // 2002:ffff:ffff:ffff:ffff:ffff:ffff:ffff
+var from_teredo = uint128.New(0, 0x2001000000000000)                // 2001::
+var to_teredo = uint128.New(0xffffffffffffffff, 0x20010000ffffffff) // 2001:0:ffff:ffff:ffff:ffff:ffff:ffff
 var last_32bits = uint128.From64(4294967295)
 
-const countryshort uint32 = 0x0000001
-const countrylong uint32 = 0x0000002
-const region uint32 = 0x0000004
-const city uint32 = 0x0000008
-const isp uint32 = 0x0000010
-const latitude uint32 = 0x0000020
-const longitude uint32 = 0x0000040
-const domain uint32 = 0x0000080
-const zipcode uint32 = 0x0000100
-const timezone uint32 = 0x0000200
-const netspeed uint32 = 0x0000400
-const iddcode uint32 = 0x0000800
-const areacode uint32 = 0x0001000
-const weatherstationcode uint32 = 0x0002000
-const weatherstationname uint32 = 0x0004000
-const mcc uint32 = 0x0008000
-const mnc uint32 = 0x0010000
-const mobilebrand uint32 = 0x0020000
-const elevation uint32 = 0x0040000
-const usagetype uint32 = 0x0080000
-const addresstype uint32 = 0x0100000
-const category uint32 = 0x0200000
-const district uint32 = 0x0400000
-const asn uint32 = 0x0800000
-const as uint32 = 0x1000000
-const asdomain uint32 = 0x2000000
-const asusagetype uint32 = 0x4000000
-const ascidr uint32 = 0x8000000
-
-const all uint32 = countryshort | countrylong | region | city | isp | latitude | longitude | domain | zipcode | timezone | netspeed | iddcode | areacode | weatherstationcode | weatherstationname | mcc | mnc | mobilebrand | elevation | usagetype | addresstype | category | district | asn | as | asdomain | asusagetype | ascidr
+// Field is a bitset selecting the geolocation fields to decode in a lookup, for example FieldCity|FieldASN.
+type Field uint32
+
+const (
+	FieldCountryShort       Field = 0x0000001 // ISO-3166 country code
+	FieldCountryLong        Field = 0x0000002 // country name
+	FieldRegion             Field = 0x0000004 // region or state name
+	FieldCity               Field = 0x0000008 // city name
+	FieldISP                Field = 0x0000010 // Internet Service Provider name
+	FieldLatitude           Field = 0x0000020 // latitude
+	FieldLongitude          Field = 0x0000040 // longitude
+	FieldDomain             Field = 0x0000080 // domain name
+	FieldZipCode            Field = 0x0000100 // ZIP or postal code
+	FieldTimeZone           Field = 0x0000200 // UTC time zone
+	FieldNetSpeed           Field = 0x0000400 // Internet connection speed
+	FieldIDDCode            Field = 0x0000800 // International Direct Dialing code
+	FieldAreaCode           Field = 0x0001000 // area code
+	FieldWeatherStationCode Field = 0x0002000 // weather station code
+	FieldWeatherStationName Field = 0x0004000 // weather station name
+	FieldMCC                Field = 0x0008000 // mobile country code
+	FieldMNC                Field = 0x0010000 // mobile network code
+	FieldMobileBrand        Field = 0x0020000 // mobile carrier brand
+	FieldElevation          Field = 0x0040000 // elevation in meters
+	FieldUsageType          Field = 0x0080000 // usage type
+	FieldAddressType        Field = 0x0100000 // address type
+	FieldCategory           Field = 0x0200000 // IAB category
+	FieldDistrict           Field = 0x0400000 // district or county name
+	FieldASN                Field = 0x0800000 // autonomous system number (ASN)
+	FieldAS                 Field = 0x1000000 // autonomous system (AS)
+	FieldASDomain           Field = 0x2000000 // AS domain
+	FieldASUsageType        Field = 0x4000000 // AS usage type
+	FieldASCIDR             Field = 0x8000000 // AS CIDR
+
+	// FieldAll selects every field.
+	FieldAll = FieldCountryShort | FieldCountryLong | FieldRegion | FieldCity | FieldISP | FieldLatitude | FieldLongitude | FieldDomain | FieldZipCode | FieldTimeZone | FieldNetSpeed | FieldIDDCode | FieldAreaCode | FieldWeatherStationCode | FieldWeatherStationName | FieldMCC | FieldMNC | FieldMobileBrand | FieldElevation | FieldUsageType | FieldAddressType | FieldCategory | FieldDistrict | FieldASN | FieldAS | FieldASDomain | FieldASUsageType | FieldASCIDR
+)
 
 const invalid_address string = "Invalid IP address."
-const missing_file string = "Invalid database file."
 const not_supported string = "This parameter is unavailable for selected data file. Please upgrade the data file."
 const invalid_bin string = "Incorrect IP2Location BIN file format. Please make sure that you are using the latest IP2Location BIN file."
 const ipv6_not_supported string = "IPv6 address missing in IPv4 BIN."
+const not_found string = "No record found."
+
+// Errors returned by the lookups, to be checked with errors.Is.
+var (
+	ErrInvalidAddress    = errors.New(invalid_address)
+	ErrIPv6NotSupported  = errors.New(ipv6_not_supported)
+	ErrFieldNotSupported = errors.New(not_supported)
+	ErrNotFound          = errors.New(not_found)
+	ErrInvalidDatabase   = errors.New(invalid_bin)
+)
 
-func reverseBytes(s []byte) {
-	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-		s[i], s[j] = s[j], s[i]
+// get IP type and calculate IP number; calculates index too if exists
+func (d *DB) checkip(ip string) (uint32, uint128.Uint128, uint32) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Zone() != "" {
+		return 0, uint128.From64(0), 0
 	}
+	return d.checkaddr(addr)
 }
 
-// get IP type and calculate IP number; calculates index too if exists
-func (d *DB) checkip(ip string) (iptype uint32, ipnum uint128.Uint128, ipindex uint32) {
-	iptype = 0
-	ipnum = uint128.From64(0)
-	ipnumtmp := uint128.From64(0)
-	ipindex = 0
-	ipaddress := net.ParseIP(ip)
+// remap IPv6 transition addresses to IPv4 and calculate index too if exists
+func (d *DB) checkipnum(iptype uint32, ipnum uint128.Uint128) (uint32, uint128.Uint128, uint32) {
+	iptype, ipnum, _ = d.mapipnum(iptype, ipnum)
+	return iptype, ipnum, d.indexof(iptype, ipnum)
+}
 
-	if ipaddress != nil {
-		v4 := ipaddress.To4()
+// calculate position of the index entry for the IP number, 0 if there is no index
+func (d *DB) indexof(iptype uint32, ipnum uint128.Uint128) uint32 {
+	ipnumtmp := uint128.From64(0)
+	var ipindex uint32 = 0
 
-		if v4 != nil {
-			iptype = 4
-			ipnum = uint128.From64(uint64(binary.BigEndian.Uint32(v4)))
-		} else {
-			v6 := ipaddress.To16()
-
-			if v6 != nil {
-				iptype = 6
-				reverseBytes(v6)
-				ipnum = uint128.FromBytes(v6)
-
-				if ipnum.Cmp(from_v4mapped) >= 0 && ipnum.Cmp(to_v4mapped) <= 0 {
-					// ipv4-mapped ipv6 should treat as ipv4 and read ipv4 data section
-					iptype = 4
-					ipnum = ipnum.Sub(from_v4mapped)
-				} else if ipnum.Cmp(from_6to4) >= 0 && ipnum.Cmp(to_6to4) <= 0 {
-					// 6to4 so need to remap to ipv4
-					iptype = 4
-					ipnum = ipnum.Rsh(80)
-					ipnum = ipnum.And(last_32bits)
-				} else if ipnum.Cmp(from_teredo) >= 0 && ipnum.Cmp(to_teredo) <= 0 {
-					// Teredo so need to remap to ipv4
-					iptype = 4
-					ipnum = uint128.Uint128{^ipnum.Lo, ^ipnum.Hi}
-					ipnum = ipnum.And(last_32bits)
-				}
-			}
-		}
-	}
 	if iptype == 4 {
 		if d.meta.ipv4indexed {
 			ipnumtmp = ipnum.Rsh(16)
@@ -279,28 +198,70 @@ func (d *DB) checkip(ip string) (iptype uint32, ipnum uint128.Uint128, ipindex u
 			ipindex = uint32(ipnumtmp.Add(uint128.From64(uint64(d.meta.ipv6indexbaseaddr))).Lo)
 		}
 	}
-	return
+	return ipindex
+}
+
+// buffers for reading rows and strings from file so that lookups do not allocate them every time
+var bufpool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 1024)
+		return &b
+	},
+}
+
+func getbuf(size uint32) *[]byte {
+	bufp := bufpool.Get().(*[]byte)
+	if uint32(cap(*bufp)) < size {
+		*bufp = make([]byte, size)
+	}
+	return bufp
 }
 
-// read byte
-func (d *DB) readuint8(pos int64) (uint8, error) {
-	var retval uint8
-	data := make([]byte, 1)
-	_, err := d.f.ReadAt(data, pos-1)
+func putbuf(bufp *[]byte) {
+	bufpool.Put(bufp)
+}
+
+// reads past the end of the file mean that an offset in the BIN is wrong
+func truncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	return err
+}
+
+// read row into the supplied buffer, or slice it straight from memory if the whole file is loaded
+func (d *DB) read_row_buf(buf []byte, pos uint32, size uint32) ([]byte, error) {
+	if d.data != nil {
+		return d.read_row(pos, size)
+	}
+	if pos < 1 {
+		return nil, truncated(io.EOF)
+	}
+	data := buf[:size]
+	_, err := d.f.ReadAt(data, int64(pos)-1)
 	if err != nil {
-		return 0, err
+		return nil, truncated(err)
 	}
-	retval = data[0]
-	return retval, nil
+	return data, nil
 }
 
 // read row
 func (d *DB) read_row(pos uint32, size uint32) ([]byte, error) {
 	pos2 := int64(pos)
+	if d.data != nil {
+		// slice straight into memory, no copy needed as rows are never modified
+		if pos2 < 1 || pos2-1+int64(size) > int64(len(d.data)) {
+			return nil, truncated(io.EOF)
+		}
+		return d.data[pos2-1 : pos2-1+int64(size)], nil
+	}
+	if pos2 < 1 {
+		return nil, truncated(io.EOF)
+	}
 	data := make([]byte, size)
 	_, err := d.f.ReadAt(data, pos2-1)
 	if err != nil {
-		return nil, err
+		return nil, truncated(err)
 	}
 	return data, nil
 }
@@ -313,23 +274,6 @@ func (d *DB) readuint32_row(row []byte, pos uint32) uint32 {
 	return retval
 }
 
-// read unsigned 32-bit integer
-func (d *DB) readuint32(pos uint32) (uint32, error) {
-	pos2 := int64(pos)
-	var retval uint32
-	data := make([]byte, 4)
-	_, err := d.f.ReadAt(data, pos2-1)
-	if err != nil {
-		return 0, err
-	}
-	buf := bytes.NewReader(data)
-	err = binary.Read(buf, binary.LittleEndian, &retval)
-	if err != nil {
-		fmt.Printf("binary read failed: %v", err)
-	}
-	return retval, nil
-}
-
 // read unsigned 128-bit integer from slices
 func (d *DB) readuint128_row(row []byte, pos uint32) uint128.Uint128 {
 	retval := uint128.From64(0)
@@ -343,48 +287,43 @@ func (d *DB) readuint128_row(row []byte, pos uint32) uint128.Uint128 {
 	return retval
 }
 
-// read unsigned 128-bit integer
-func (d *DB) readuint128(pos uint32) (uint128.Uint128, error) {
-	pos2 := int64(pos)
-	retval := uint128.From64(0)
-	data := make([]byte, 16)
-	_, err := d.f.ReadAt(data, pos2-1)
-	if err != nil {
-		return uint128.From64(0), err
-	}
-
-	// little endian to big endian
-	// for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
-	// data[i], data[j] = data[j], data[i]
-	// }
-	retval = uint128.FromBytes(data)
-	return retval, nil
-}
-
 // read string
 func (d *DB) readstr(pos uint32) (string, error) {
 	pos2 := int64(pos)
-	readlen := 256 // max size of string field + 1 byte for the length
+	if d.data != nil {
+		if pos2 >= int64(len(d.data)) {
+			return "", truncated(io.EOF)
+		}
+		strlen := int64(d.data[pos2])
+		if pos2+1+strlen > int64(len(d.data)) {
+			return "", truncated(io.ErrUnexpectedEOF)
+		}
+		data := d.data[pos2+1 : pos2+1+strlen]
+		if d.mmap {
+			return string(data), nil // mapping goes away on Close so the string cannot point into it
+		}
+		return convertBytesToString(data), nil
+	}
+	readlen := uint32(256) // max size of string field + 1 byte for the length
 	var retval string
-	data := make([]byte, readlen)
-	_, err := d.f.ReadAt(data, pos2)
-	if err != nil && err.Error() != "EOF" { // bypass EOF error coz we are reading 256 which may hit EOF
+	bufp := getbuf(readlen)
+	defer putbuf(bufp)
+	data := (*bufp)[:readlen]
+	n, err := d.f.ReadAt(data, pos2)
+	if err != nil && err != io.EOF { // bypass EOF error coz we are reading 256 which may hit EOF
 		return "", err
 	}
-	strlen := data[0]
-	retval = convertBytesToString(data[1:(strlen + 1)])
+	if n == 0 {
+		return "", truncated(io.EOF)
+	}
+	strlen := int(data[0])
+	if strlen+1 > n {
+		return "", truncated(io.ErrUnexpectedEOF)
+	}
+	retval = string(data[1:(strlen + 1)]) // copy as the buffer goes back to the pool
 	return retval, nil
 }
 
-// read float from slices
-func (d *DB) readfloat_row(row []byte, pos uint32) float32 {
-	var retval float32
-	data := row[pos : pos+4]
-	bits := binary.LittleEndian.Uint32(data)
-	retval = math.Float32frombits(bits)
-	return retval
-}
-
 func fatal(db *DB, err error) (*DB, error) {
 	_ = db.f.Close()
 	return nil, err
@@ -406,35 +345,20 @@ func OpenDB(dbpath string) (*DB, error) {
 func OpenDBWithReader(reader DBReader) (*DB, error) {
 	var db = &DB{}
 
-	_max_ipv6_range := big.NewInt(0)
-	_max_ipv6_range.SetString("340282366920938463463374607431768211455", 10)
-	max_ipv6_range = uint128.FromBig(_max_ipv6_range)
-
-	_from_6to4 := big.NewInt(0)
-	_from_6to4.SetString("42545680458834377588178886921629466624", 10)
-	from_6to4 = uint128.FromBig(_from_6to4)
-
-	_to_6to4 := big.NewInt(0)
-	_to_6to4.SetString("42550872755692912415807417417958686719", 10)
-	to_6to4 = uint128.FromBig(_to_6to4)
-
-	_from_teredo := big.NewInt(0)
-	_from_teredo.SetString("42540488161975842760550356425300246528", 10)
-	from_teredo = uint128.FromBig(_from_teredo)
-
-	_to_teredo := big.NewInt(0)
-	_to_teredo.SetString("42540488241204005274814694018844196863", 10)
-	to_teredo = uint128.FromBig(_to_teredo)
-
 	db.f = reader
 
+	if m, ok := reader.(*memoryReader); ok {
+		db.data = m.data
+		db.mmap = m.mmap
+	}
+
 	var row []byte
 	var err error
 	readlen := uint32(64) // 64-byte header
 
 	row, err = db.read_row(1, readlen)
 	if err != nil {
-		return fatal(db, err)
+		return fatal(db, err) // ErrInvalidDatabase if too short for the header
 	}
 	db.meta.databasetype = row[0]
 	db.meta.databasecolumn = row[1]
@@ -452,8 +376,11 @@ func OpenDBWithReader(reader DBReader) (*DB, error) {
 	db.meta.filesize = db.readuint32_row(row, 31)
 
 	// check if is correct BIN (should be 1 for IP2Location BIN file), also checking for zipped file (PK being the first 2 chars)
-	if (db.meta.productcode != 1 && db.meta.databaseyear >= 21) || (db.meta.databasetype == 80 && db.meta.databasecolumn == 75) { // only BINs from Jan 2021 onwards have this byte set
-		return fatal(db, errors.New(invalid_bin))
+	if db.meta.databasetype == 80 && db.meta.databasecolumn == 75 {
+		return fatal(db, fmt.Errorf("%w: the file is a ZIP archive, open it with OpenDBFromZip", ErrInvalidDatabase))
+	}
+	if db.meta.productcode != 1 && db.meta.databaseyear >= 21 { // only BINs from Jan 2021 onwards have this byte set
+		return fatal(db, ErrInvalidDatabase)
 	}
 
 	if db.meta.ipv4indexbaseaddr > 0 {
@@ -464,125 +391,43 @@ func OpenDBWithReader(reader DBReader) (*DB, error) {
 		db.meta.ipv6indexed = true
 	}
 
-	db.meta.ipv4columnsize = uint32(db.meta.databasecolumn << 2)              // 4 bytes each column
-	db.meta.ipv6columnsize = uint32(16 + ((db.meta.databasecolumn - 1) << 2)) // 4 bytes each column, except IPFrom column which is 16 bytes
+	// readers caching blocks of the file keep the indexes, which every lookup reads
+	if p, ok := reader.(interface{ pin(off int64, n int64) }); ok {
+		if db.meta.ipv4indexed {
+			p.pin(int64(db.meta.ipv4indexbaseaddr)-1, 65536*8)
+		}
+		if db.meta.ipv6indexed {
+			p.pin(int64(db.meta.ipv6indexbaseaddr)-1, 65536*8)
+		}
+	}
 
 	dbt := db.meta.databasetype
-
-	if country_position[dbt] != 0 {
-		db.country_position_offset = uint32(country_position[dbt]-2) << 2
-		db.country_enabled = true
-	}
-	if region_position[dbt] != 0 {
-		db.region_position_offset = uint32(region_position[dbt]-2) << 2
-		db.region_enabled = true
-	}
-	if city_position[dbt] != 0 {
-		db.city_position_offset = uint32(city_position[dbt]-2) << 2
-		db.city_enabled = true
-	}
-	if isp_position[dbt] != 0 {
-		db.isp_position_offset = uint32(isp_position[dbt]-2) << 2
-		db.isp_enabled = true
-	}
-	if domain_position[dbt] != 0 {
-		db.domain_position_offset = uint32(domain_position[dbt]-2) << 2
-		db.domain_enabled = true
+	if dbt == 0 {
+		return fatal(db, fmt.Errorf("%w: unknown database type %d", ErrInvalidDatabase, dbt))
 	}
-	if zipcode_position[dbt] != 0 {
-		db.zipcode_position_offset = uint32(zipcode_position[dbt]-2) << 2
-		db.zipcode_enabled = true
-	}
-	if latitude_position[dbt] != 0 {
-		db.latitude_position_offset = uint32(latitude_position[dbt]-2) << 2
-		db.latitude_enabled = true
-	}
-	if longitude_position[dbt] != 0 {
-		db.longitude_position_offset = uint32(longitude_position[dbt]-2) << 2
-		db.longitude_enabled = true
-	}
-	if timezone_position[dbt] != 0 {
-		db.timezone_position_offset = uint32(timezone_position[dbt]-2) << 2
-		db.timezone_enabled = true
-	}
-	if netspeed_position[dbt] != 0 {
-		db.netspeed_position_offset = uint32(netspeed_position[dbt]-2) << 2
-		db.netspeed_enabled = true
-	}
-	if iddcode_position[dbt] != 0 {
-		db.iddcode_position_offset = uint32(iddcode_position[dbt]-2) << 2
-		db.iddcode_enabled = true
-	}
-	if areacode_position[dbt] != 0 {
-		db.areacode_position_offset = uint32(areacode_position[dbt]-2) << 2
-		db.areacode_enabled = true
-	}
-	if weatherstationcode_position[dbt] != 0 {
-		db.weatherstationcode_position_offset = uint32(weatherstationcode_position[dbt]-2) << 2
-		db.weatherstationcode_enabled = true
-	}
-	if weatherstationname_position[dbt] != 0 {
-		db.weatherstationname_position_offset = uint32(weatherstationname_position[dbt]-2) << 2
-		db.weatherstationname_enabled = true
-	}
-	if mcc_position[dbt] != 0 {
-		db.mcc_position_offset = uint32(mcc_position[dbt]-2) << 2
-		db.mcc_enabled = true
-	}
-	if mnc_position[dbt] != 0 {
-		db.mnc_position_offset = uint32(mnc_position[dbt]-2) << 2
-		db.mnc_enabled = true
-	}
-	if mobilebrand_position[dbt] != 0 {
-		db.mobilebrand_position_offset = uint32(mobilebrand_position[dbt]-2) << 2
-		db.mobilebrand_enabled = true
-	}
-	if elevation_position[dbt] != 0 {
-		db.elevation_position_offset = uint32(elevation_position[dbt]-2) << 2
-		db.elevation_enabled = true
-	}
-	if usagetype_position[dbt] != 0 {
-		db.usagetype_position_offset = uint32(usagetype_position[dbt]-2) << 2
-		db.usagetype_enabled = true
-	}
-	if addresstype_position[dbt] != 0 {
-		db.addresstype_position_offset = uint32(addresstype_position[dbt]-2) << 2
-		db.addresstype_enabled = true
-	}
-	if category_position[dbt] != 0 {
-		db.category_position_offset = uint32(category_position[dbt]-2) << 2
-		db.category_enabled = true
-	}
-	if district_position[dbt] != 0 {
-		db.district_position_offset = uint32(district_position[dbt]-2) << 2
-		db.district_enabled = true
-	}
-	if asn_position[dbt] != 0 {
-		db.asn_position_offset = uint32(asn_position[dbt]-2) << 2
-		db.asn_enabled = true
-	}
-	if as_position[dbt] != 0 {
-		db.as_position_offset = uint32(as_position[dbt]-2) << 2
-		db.as_enabled = true
-	}
-	if asdomain_position[dbt] != 0 {
-		db.asdomain_position_offset = uint32(asdomain_position[dbt]-2) << 2
-		db.asdomain_enabled = true
-	}
-	if asusagetype_position[dbt] != 0 {
-		db.asusagetype_position_offset = uint32(asusagetype_position[dbt]-2) << 2
-		db.asusagetype_enabled = true
-	}
-	if ascidr_position[dbt] != 0 {
-		db.ascidr_position_offset = uint32(ascidr_position[dbt]-2) << 2
-		db.ascidr_enabled = true
+
+	// every column the database type reads must be in the rows
+	cols := schemafor(dbt, db.meta.databasecolumn)
+	for _, c := range cols {
+		if c.Position > int(db.meta.databasecolumn) {
+			return fatal(db, fmt.Errorf("%w: %d columns are too few for DB%d", ErrInvalidDatabase, db.meta.databasecolumn, dbt))
+		}
 	}
 
+	db.meta.ipv4columnsize = uint32(db.meta.databasecolumn) << 2          // 4 bytes each column
+	db.meta.ipv6columnsize = 16 + (uint32(db.meta.databasecolumn-1) << 2) // 4 bytes each column, except IPFrom column which is 16 bytes
+
+	db.setcolumns(cols)
 	db.metaok = true
 
 	return db, nil
 }
 
+// SupportedFields returns the fields available in the opened BIN database.
+func (d *DB) SupportedFields() Field {
+	return d.fields
+}
+
 // Open takes the path to the IP2Location BIN database file. It will read all the metadata required to
 // be able to extract the embedded geolocation data.
 //
@@ -593,6 +438,11 @@ func Open(dbpath string) {
 		return
 	}
 
+	defaultmu.Lock()
+	defer defaultmu.Unlock()
+	if defaultDB.f != nil {
+		defaultDB.Close()
+	}
 	defaultDB = db
 }
 
@@ -600,7 +450,12 @@ func Open(dbpath string) {
 //
 // Deprecated: No longer being updated.
 func Close() {
-	defaultDB.Close()
+	defaultmu.Lock()
+	defer defaultmu.Unlock()
+	if defaultDB.f != nil {
+		defaultDB.Close()
+	}
+	defaultDB = &DB{}
 }
 
 // Api_version returns the version of the component.
@@ -618,43 +473,11 @@ func (d *DB) DatabaseVersion() string {
 	return "20" + strconv.Itoa(int(d.meta.databaseyear)) + "." + strconv.Itoa(int(d.meta.databasemonth)) + "." + strconv.Itoa(int(d.meta.databaseday))
 }
 
-// populate record with message
-func loadmessage(mesg string) IP2Locationrecord {
-	var x IP2Locationrecord
-
-	x.Country_short = mesg
-	x.Country_long = mesg
-	x.Region = mesg
-	x.City = mesg
-	x.Isp = mesg
-	x.Domain = mesg
-	x.Zipcode = mesg
-	x.Timezone = mesg
-	x.Netspeed = mesg
-	x.Iddcode = mesg
-	x.Areacode = mesg
-	x.Weatherstationcode = mesg
-	x.Weatherstationname = mesg
-	x.Mcc = mesg
-	x.Mnc = mesg
-	x.Mobilebrand = mesg
-	x.Usagetype = mesg
-	x.Addresstype = mesg
-	x.Category = mesg
-	x.District = mesg
-	x.Asn = mesg
-	x.As = mesg
-	x.Asdomain = mesg
-	x.Asusagetype = mesg
-	x.Ascidr = mesg
-
-	return x
-}
-
-func handleError(rec IP2Locationrecord, err error) IP2Locationrecord {
-	if err != nil {
-		fmt.Print(err)
-	}
+// queries defaultDB, which Open and Close may replace at any time
+func defaultquery(ipaddress string, mode Field) IP2Locationrecord {
+	defaultmu.RLock()
+	defer defaultmu.RUnlock()
+	rec, _ := defaultDB.query(ipaddress, mode) // the deprecated functions have no way to return the error
 	return rec
 }
 
@@ -669,315 +492,339 @@ func convertBytesToString(b []byte) string {
 //
 // Deprecated: No longer being updated.
 func Get_all(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, all))
+	return defaultquery(ipaddress, FieldAll)
 }
 
 // Get_country_short will return the ISO-3166 country code based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_country_short(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, countryshort))
+	return defaultquery(ipaddress, FieldCountryShort)
 }
 
 // Get_country_long will return the country name based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_country_long(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, countrylong))
+	return defaultquery(ipaddress, FieldCountryLong)
 }
 
 // Get_region will return the region name based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_region(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, region))
+	return defaultquery(ipaddress, FieldRegion)
 }
 
 // Get_city will return the city name based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_city(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, city))
+	return defaultquery(ipaddress, FieldCity)
 }
 
 // Get_isp will return the Internet Service Provider name based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_isp(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, isp))
+	return defaultquery(ipaddress, FieldISP)
 }
 
 // Get_latitude will return the latitude based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_latitude(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, latitude))
+	return defaultquery(ipaddress, FieldLatitude)
 }
 
 // Get_longitude will return the longitude based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_longitude(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, longitude))
+	return defaultquery(ipaddress, FieldLongitude)
 }
 
 // Get_domain will return the domain name based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_domain(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, domain))
+	return defaultquery(ipaddress, FieldDomain)
 }
 
 // Get_zipcode will return the postal code based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_zipcode(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, zipcode))
+	return defaultquery(ipaddress, FieldZipCode)
 }
 
 // Get_timezone will return the time zone based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_timezone(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, timezone))
+	return defaultquery(ipaddress, FieldTimeZone)
 }
 
 // Get_netspeed will return the Internet connection speed based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_netspeed(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, netspeed))
+	return defaultquery(ipaddress, FieldNetSpeed)
 }
 
 // Get_iddcode will return the International Direct Dialing code based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_iddcode(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, iddcode))
+	return defaultquery(ipaddress, FieldIDDCode)
 }
 
 // Get_areacode will return the area code based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_areacode(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, areacode))
+	return defaultquery(ipaddress, FieldAreaCode)
 }
 
 // Get_weatherstationcode will return the weather station code based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_weatherstationcode(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, weatherstationcode))
+	return defaultquery(ipaddress, FieldWeatherStationCode)
 }
 
 // Get_weatherstationname will return the weather station name based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_weatherstationname(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, weatherstationname))
+	return defaultquery(ipaddress, FieldWeatherStationName)
 }
 
 // Get_mcc will return the mobile country code based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_mcc(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, mcc))
+	return defaultquery(ipaddress, FieldMCC)
 }
 
 // Get_mnc will return the mobile network code based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_mnc(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, mnc))
+	return defaultquery(ipaddress, FieldMNC)
 }
 
 // Get_mobilebrand will return the mobile carrier brand based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_mobilebrand(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, mobilebrand))
+	return defaultquery(ipaddress, FieldMobileBrand)
 }
 
 // Get_elevation will return the elevation in meters based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_elevation(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, elevation))
+	return defaultquery(ipaddress, FieldElevation)
 }
 
 // Get_usagetype will return the usage type based on the queried IP address.
 //
 // Deprecated: No longer being updated.
 func Get_usagetype(ipaddress string) IP2Locationrecord {
-	return handleError(defaultDB.query(ipaddress, usagetype))
+	return defaultquery(ipaddress, FieldUsageType)
 }
 
 // Get_all will return all geolocation fields based on the queried IP address.
 func (d *DB) Get_all(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, all)
+	return d.query(ipaddress, FieldAll)
 }
 
 // Get_country_short will return the ISO-3166 country code based on the queried IP address.
 func (d *DB) Get_country_short(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, countryshort)
+	return d.query(ipaddress, FieldCountryShort)
 }
 
 // Get_country_long will return the country name based on the queried IP address.
 func (d *DB) Get_country_long(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, countrylong)
+	return d.query(ipaddress, FieldCountryLong)
 }
 
 // Get_region will return the region name based on the queried IP address.
 func (d *DB) Get_region(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, region)
+	return d.query(ipaddress, FieldRegion)
 }
 
 // Get_city will return the city name based on the queried IP address.
 func (d *DB) Get_city(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, city)
+	return d.query(ipaddress, FieldCity)
 }
 
 // Get_isp will return the Internet Service Provider name based on the queried IP address.
 func (d *DB) Get_isp(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, isp)
+	return d.query(ipaddress, FieldISP)
 }
 
 // Get_latitude will return the latitude based on the queried IP address.
 func (d *DB) Get_latitude(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, latitude)
+	return d.query(ipaddress, FieldLatitude)
 }
 
 // Get_longitude will return the longitude based on the queried IP address.
 func (d *DB) Get_longitude(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, longitude)
+	return d.query(ipaddress, FieldLongitude)
 }
 
 // Get_domain will return the domain name based on the queried IP address.
 func (d *DB) Get_domain(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, domain)
+	return d.query(ipaddress, FieldDomain)
 }
 
 // Get_zipcode will return the postal code based on the queried IP address.
 func (d *DB) Get_zipcode(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, zipcode)
+	return d.query(ipaddress, FieldZipCode)
 }
 
 // Get_timezone will return the time zone based on the queried IP address.
 func (d *DB) Get_timezone(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, timezone)
+	return d.query(ipaddress, FieldTimeZone)
 }
 
 // Get_netspeed will return the Internet connection speed based on the queried IP address.
 func (d *DB) Get_netspeed(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, netspeed)
+	return d.query(ipaddress, FieldNetSpeed)
 }
 
 // Get_iddcode will return the International Direct Dialing code based on the queried IP address.
 func (d *DB) Get_iddcode(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, iddcode)
+	return d.query(ipaddress, FieldIDDCode)
 }
 
 // Get_areacode will return the area code based on the queried IP address.
 func (d *DB) Get_areacode(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, areacode)
+	return d.query(ipaddress, FieldAreaCode)
 }
 
 // Get_weatherstationcode will return the weather station code based on the queried IP address.
 func (d *DB) Get_weatherstationcode(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, weatherstationcode)
+	return d.query(ipaddress, FieldWeatherStationCode)
 }
 
 // Get_weatherstationname will return the weather station name based on the queried IP address.
 func (d *DB) Get_weatherstationname(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, weatherstationname)
+	return d.query(ipaddress, FieldWeatherStationName)
 }
 
 // Get_mcc will return the mobile country code based on the queried IP address.
 func (d *DB) Get_mcc(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, mcc)
+	return d.query(ipaddress, FieldMCC)
 }
 
 // Get_mnc will return the mobile network code based on the queried IP address.
 func (d *DB) Get_mnc(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, mnc)
+	return d.query(ipaddress, FieldMNC)
 }
 
 // Get_mobilebrand will return the mobile carrier brand based on the queried IP address.
 func (d *DB) Get_mobilebrand(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, mobilebrand)
+	return d.query(ipaddress, FieldMobileBrand)
 }
 
 // Get_elevation will return the elevation in meters based on the queried IP address.
 func (d *DB) Get_elevation(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, elevation)
+	return d.query(ipaddress, FieldElevation)
 }
 
 // Get_usagetype will return the usage type based on the queried IP address.
 func (d *DB) Get_usagetype(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, usagetype)
+	return d.query(ipaddress, FieldUsageType)
 }
 
 // Get_addresstype will return the address type based on the queried IP address.
 func (d *DB) Get_addresstype(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, addresstype)
+	return d.query(ipaddress, FieldAddressType)
 }
 
 // Get_category will return the category based on the queried IP address.
 func (d *DB) Get_category(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, category)
+	return d.query(ipaddress, FieldCategory)
 }
 
 // Get_district will return the district name based on the queried IP address.
 func (d *DB) Get_district(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, district)
+	return d.query(ipaddress, FieldDistrict)
 }
 
 // Get_asn will return the autonomous system number (ASN) based on the queried IP address.
 func (d *DB) Get_asn(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, asn)
+	return d.query(ipaddress, FieldASN)
 }
 
 // Get_as will return the autonomous system (AS) based on the queried IP address.
 func (d *DB) Get_as(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, as)
+	return d.query(ipaddress, FieldAS)
 }
 
 // Get_as will return the AS domain based on the queried IP address.
 func (d *DB) Get_asdomain(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, asdomain)
+	return d.query(ipaddress, FieldASDomain)
 }
 
 // Get_as will return the AS usage type based on the queried IP address.
 func (d *DB) Get_asusagetype(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, asusagetype)
+	return d.query(ipaddress, FieldASUsageType)
 }
 
 // Get_as will return the AS CIDR based on the queried IP address.
 func (d *DB) Get_ascidr(ipaddress string) (IP2Locationrecord, error) {
-	return d.query(ipaddress, ascidr)
+	return d.query(ipaddress, FieldASCIDR)
 }
 
 // main query
-func (d *DB) query(ipaddress string, mode uint32) (IP2Locationrecord, error) {
-	x := loadmessage(not_supported) // default message
+func (d *DB) query(ipaddress string, mode Field) (IP2Locationrecord, error) {
+	// check IP type and return IP number & index (if exists)
+	iptype, ipno, ipindex := d.checkip(ipaddress)
+
+	return d.querynum(iptype, ipno, ipindex, mode)
+}
+
+// query by IP number
+func (d *DB) querynum(iptype uint32, ipno uint128.Uint128, ipindex uint32, mode Field) (IP2Locationrecord, error) {
+	var x IP2Locationrecord
+	err := d.queryinto(iptype, ipno, ipindex, mode, &x, nil)
+	return x, err
+}
+
+// the row matched by a query
+type rowmatch struct {
+	iptype uint32          // 4 or 6, the data section the row is in
+	ipfrom uint128.Uint128 // first IP number of the range
+	ipto   uint128.Uint128 // first IP number of the next range
+	index  uint32          // row number in the data section
+	found  bool
+}
+
+// query by IP number and fill the supplied record, and the matched row if m is not nil
+func (d *DB) queryinto(iptype uint32, ipno uint128.Uint128, ipindex uint32, mode Field, x *IP2Locationrecord, m *rowmatch) error {
+	*x = IP2Locationrecord{}
 
 	// read metadata
 	if !d.metaok {
-		x = loadmessage(missing_file)
-		return x, nil
+		return ErrInvalidDatabase
 	}
 
-	// check IP type and return IP number & index (if exists)
-	iptype, ipno, ipindex := d.checkip(ipaddress)
-
 	if iptype == 0 {
-		x = loadmessage(invalid_address)
-		return x, nil
+		return ErrInvalidAddress
+	}
+
+	if mode != 0 && mode&d.fields == 0 {
+		return ErrFieldNotSupported
 	}
 
 	var err error
 	var colsize uint32
 	var baseaddr uint32
+	var count uint32
 	var low uint32
 	var high uint32
 	var mid uint32
@@ -992,44 +839,55 @@ func (d *DB) query(ipaddress string, mode uint32) (IP2Locationrecord, error) {
 
 	if iptype == 4 {
 		baseaddr = d.meta.ipv4databaseaddr
-		high = d.meta.ipv4databasecount
+		count = d.meta.ipv4databasecount
 		maxip = max_ipv4_range
 		colsize = d.meta.ipv4columnsize
 	} else {
 		if d.meta.ipv6databasecount == 0 {
-			x = loadmessage(ipv6_not_supported)
-			return x, nil
+			return ErrIPv6NotSupported
 		}
 		firstcol = 16 // 16 bytes for ip from
 		baseaddr = d.meta.ipv6databaseaddr
-		high = d.meta.ipv6databasecount
+		count = d.meta.ipv6databasecount
 		maxip = max_ipv6_range
 		colsize = d.meta.ipv6columnsize
 	}
 
+	// reading IP From + whole row + next IP From
+	readlen = colsize + firstcol
+	bufp := getbuf(readlen)
+	defer putbuf(bufp)
+
+	high = count
+
 	// reading index
 	if ipindex > 0 {
-		row, err = d.read_row(ipindex, 8) // 4 bytes each for IP From and IP To
+		row, err = d.read_row_buf(*bufp, ipindex, 8) // 4 bytes each for IP From and IP To
 		if err != nil {
-			return x, err
+			return err
 		}
 		low = d.readuint32_row(row, 0)
 		high = d.readuint32_row(row, 4)
+		if low > high || high > count {
+			return fmt.Errorf("%w: index entry at %d gives rows %d to %d", ErrInvalidDatabase, ipindex, low, high)
+		}
 	}
 
 	if ipno.Cmp(maxip) >= 0 {
 		ipno = ipno.Sub(uint128.From64(1))
 	}
 
+	if d.cache != nil && d.cache.get(iptype, ipno, mode, x, m) {
+		return nil
+	}
+
 	for low <= high {
-		mid = ((low + high) >> 1)
+		mid = low + ((high - low) >> 1)
 		rowoffset = baseaddr + (mid * colsize)
 
-		// reading IP From + whole row + next IP From
-		readlen = colsize + firstcol
-		fullrow, err = d.read_row(rowoffset, readlen)
+		fullrow, err = d.read_row_buf(*bufp, rowoffset, readlen)
 		if err != nil {
-			return x, err
+			return err
 		}
 
 		if iptype == 4 {
@@ -1048,187 +906,55 @@ func (d *DB) query(ipaddress string, mode uint32) (IP2Locationrecord, error) {
 			rowlen := colsize - firstcol
 			row = fullrow[firstcol:(firstcol + rowlen)] // extract the actual row data
 
-			if mode&countryshort == 1 && d.country_enabled {
-				if x.Country_short, err = d.readstr(d.readuint32_row(row, d.country_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&countrylong != 0 && d.country_enabled {
-				if x.Country_long, err = d.readstr(d.readuint32_row(row, d.country_position_offset) + 3); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&region != 0 && d.region_enabled {
-				if x.Region, err = d.readstr(d.readuint32_row(row, d.region_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&city != 0 && d.city_enabled {
-				if x.City, err = d.readstr(d.readuint32_row(row, d.city_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&isp != 0 && d.isp_enabled {
-				if x.Isp, err = d.readstr(d.readuint32_row(row, d.isp_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&latitude != 0 && d.latitude_enabled {
-				x.Latitude = d.readfloat_row(row, d.latitude_position_offset)
-			}
-
-			if mode&longitude != 0 && d.longitude_enabled {
-				x.Longitude = d.readfloat_row(row, d.longitude_position_offset)
-			}
-
-			if mode&domain != 0 && d.domain_enabled {
-				if x.Domain, err = d.readstr(d.readuint32_row(row, d.domain_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&zipcode != 0 && d.zipcode_enabled {
-				if x.Zipcode, err = d.readstr(d.readuint32_row(row, d.zipcode_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&timezone != 0 && d.timezone_enabled {
-				if x.Timezone, err = d.readstr(d.readuint32_row(row, d.timezone_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&netspeed != 0 && d.netspeed_enabled {
-				if x.Netspeed, err = d.readstr(d.readuint32_row(row, d.netspeed_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&iddcode != 0 && d.iddcode_enabled {
-				if x.Iddcode, err = d.readstr(d.readuint32_row(row, d.iddcode_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&areacode != 0 && d.areacode_enabled {
-				if x.Areacode, err = d.readstr(d.readuint32_row(row, d.areacode_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&weatherstationcode != 0 && d.weatherstationcode_enabled {
-				if x.Weatherstationcode, err = d.readstr(d.readuint32_row(row, d.weatherstationcode_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&weatherstationname != 0 && d.weatherstationname_enabled {
-				if x.Weatherstationname, err = d.readstr(d.readuint32_row(row, d.weatherstationname_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&mcc != 0 && d.mcc_enabled {
-				if x.Mcc, err = d.readstr(d.readuint32_row(row, d.mcc_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&mnc != 0 && d.mnc_enabled {
-				if x.Mnc, err = d.readstr(d.readuint32_row(row, d.mnc_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&mobilebrand != 0 && d.mobilebrand_enabled {
-				if x.Mobilebrand, err = d.readstr(d.readuint32_row(row, d.mobilebrand_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&elevation != 0 && d.elevation_enabled {
-				res, err := d.readstr(d.readuint32_row(row, d.elevation_position_offset))
-				if err != nil {
-					return x, err
-				}
-
-				f, _ := strconv.ParseFloat(res, 32)
-				x.Elevation = float32(f)
+			match := rowmatch{iptype: iptype, ipfrom: ipfrom, ipto: ipto, index: mid, found: true}
+			if m != nil {
+				*m = match
 			}
 
-			if mode&usagetype != 0 && d.usagetype_enabled {
-				if x.Usagetype, err = d.readstr(d.readuint32_row(row, d.usagetype_position_offset)); err != nil {
-					return x, err
+			if d.cache != nil {
+				// cache all the fields so that the range serves any later query
+				var rec IP2Locationrecord
+				if err = d.readrecord(row, d.fields, &rec); err != nil {
+					return err
 				}
+				d.cache.put(match, rec)
+				copyfields(x, &rec, mode&rec.Fields) // without the fields the decoders dropped
+				return nil
 			}
 
-			if mode&addresstype != 0 && d.addresstype_enabled {
-				if x.Addresstype, err = d.readstr(d.readuint32_row(row, d.addresstype_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&category != 0 && d.category_enabled {
-				if x.Category, err = d.readstr(d.readuint32_row(row, d.category_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&district != 0 && d.district_enabled {
-				if x.District, err = d.readstr(d.readuint32_row(row, d.district_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&asn != 0 && d.asn_enabled {
-				if x.Asn, err = d.readstr(d.readuint32_row(row, d.asn_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&as != 0 && d.as_enabled {
-				if x.As, err = d.readstr(d.readuint32_row(row, d.as_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&asdomain != 0 && d.asdomain_enabled {
-				if x.Asdomain, err = d.readstr(d.readuint32_row(row, d.asdomain_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&asusagetype != 0 && d.asusagetype_enabled {
-				if x.Asusagetype, err = d.readstr(d.readuint32_row(row, d.asusagetype_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			if mode&ascidr != 0 && d.ascidr_enabled {
-				if x.Ascidr, err = d.readstr(d.readuint32_row(row, d.ascidr_position_offset)); err != nil {
-					return x, err
-				}
-			}
-
-			return x, nil
+			return d.readrecord(row, mode, x)
 		} else {
 			if ipno.Cmp(ipfrom) < 0 {
+				if mid == 0 {
+					break
+				}
 				high = mid - 1
 			} else {
 				low = mid + 1
 			}
 		}
 	}
-	return x, nil
+	return ErrNotFound
+}
+
+// decode the selected fields of a row, not including its IP From column
+func (d *DB) readrecord(row []byte, mode Field, x *IP2Locationrecord) error {
+	x.Fields = mode & d.fields // before the decoders, which drop the fields they cannot parse
+	for _, c := range d.readers {
+		if mode&c.field != 0 {
+			if err := c.decode(d, d.readuint32_row(row, c.offset), mode, x); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (d *DB) Close() {
+	if d.cache != nil {
+		d.cache.clear()
+	}
+	d.data = nil
 	_ = d.f.Close()
 }
 
@@ -0,0 +1,178 @@
+package ip2locationtest
+
+import (
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// The Call struct stores one lookup made on a Fake.
+type Call struct {
+	IP     string
+	Fields ip2location.Field
+}
+
+// an answer set on a Fake
+type fakeentry struct {
+	prefix netip.Prefix
+	rec    ip2location.IP2Locationrecord
+	err    error
+}
+
+// Fake is an ip2location.Querier answering from records set per IP address or CIDR block, for tests of code
+// taking a Querier that do not need a BIN database. The most specific entry containing the address answers,
+// addresses without an entry get ip2location.ErrNotFound. Like a DB, Query returns only the fields asked for
+// and lists them in Fields; a record set with Fields acts as a database having only those fields, giving
+// ip2location.ErrFieldNotSupported when none of the fields asked for are among them. Calls records the lookups
+// made. The zero value is ready to use and safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	entries []fakeentry
+	calls   []Call
+}
+
+var _ ip2location.Querier = (*Fake)(nil)
+
+// parse an IP address or a CIDR block, storing IPv4-mapped IPv6 ones as IPv4 as ip2location.Overlay does
+func fakeprefix(s string) netip.Prefix {
+	if strings.Contains(s, "/") {
+		p := netip.MustParsePrefix(s)
+		if p.Addr().Is4In6() {
+			if p.Bits() < 96 {
+				panic("ip2locationtest: IPv4-mapped prefix shorter than /96: " + s)
+			}
+			p = netip.PrefixFrom(p.Addr().Unmap(), p.Bits()-96)
+		}
+		return p.Masked()
+	}
+	addr := netip.MustParseAddr(s).Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen())
+}
+
+func (f *Fake) set(e fakeentry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := range f.entries {
+		if f.entries[i].prefix == e.prefix {
+			f.entries[i] = e
+			return
+		}
+	}
+	f.entries = append(f.entries, e)
+}
+
+// Set makes lookups of the IP address or CIDR block return the record. It panics if ip is invalid.
+func (f *Fake) Set(ip string, rec ip2location.IP2Locationrecord) {
+	f.set(fakeentry{prefix: fakeprefix(ip), rec: rec})
+}
+
+// SetError makes lookups of the IP address or CIDR block fail with err. It panics if ip is invalid.
+func (f *Fake) SetError(ip string, err error) {
+	f.set(fakeentry{prefix: fakeprefix(ip), err: err})
+}
+
+// Calls returns the lookups made so far, in order.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call(nil), f.calls...)
+}
+
+// Get_all will return the record set for the IP address.
+func (f *Fake) Get_all(ipaddress string) (ip2location.IP2Locationrecord, error) {
+	return f.Query(ipaddress, ip2location.FieldAll)
+}
+
+// Query will return the record set for the IP address.
+func (f *Fake) Query(ipaddress string, fields ip2location.Field) (ip2location.IP2Locationrecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, Call{IP: ipaddress, Fields: fields})
+
+	addr, err := netip.ParseAddr(ipaddress)
+	if err != nil || addr.Zone() != "" {
+		return ip2location.IP2Locationrecord{}, ip2location.ErrInvalidAddress
+	}
+	addr = addr.Unmap()
+
+	var best *fakeentry
+	for i := range f.entries {
+		e := &f.entries[i]
+		if e.prefix.Contains(addr) && (best == nil || e.prefix.Bits() > best.prefix.Bits()) {
+			best = e
+		}
+	}
+	if best == nil {
+		return ip2location.IP2Locationrecord{}, ip2location.ErrNotFound
+	}
+	if best.err != nil {
+		return ip2location.IP2Locationrecord{}, best.err
+	}
+	if fields != 0 && best.rec.Fields != 0 && fields&best.rec.Fields == 0 {
+		return ip2location.IP2Locationrecord{}, ip2location.ErrFieldNotSupported // as a DB without the fields does
+	}
+	return maskrecord(&best.rec, fields), nil
+}
+
+// the text fields of a record
+var fakestrings = []struct {
+	field ip2location.Field
+	value func(x *ip2location.IP2Locationrecord) *string
+}{
+	{ip2location.FieldCountryShort, func(x *ip2location.IP2Locationrecord) *string { return &x.Country_short }},
+	{ip2location.FieldCountryLong, func(x *ip2location.IP2Locationrecord) *string { return &x.Country_long }},
+	{ip2location.FieldRegion, func(x *ip2location.IP2Locationrecord) *string { return &x.Region }},
+	{ip2location.FieldCity, func(x *ip2location.IP2Locationrecord) *string { return &x.City }},
+	{ip2location.FieldISP, func(x *ip2location.IP2Locationrecord) *string { return &x.Isp }},
+	{ip2location.FieldDomain, func(x *ip2location.IP2Locationrecord) *string { return &x.Domain }},
+	{ip2location.FieldZipCode, func(x *ip2location.IP2Locationrecord) *string { return &x.Zipcode }},
+	{ip2location.FieldTimeZone, func(x *ip2location.IP2Locationrecord) *string { return &x.Timezone }},
+	{ip2location.FieldNetSpeed, func(x *ip2location.IP2Locationrecord) *string { return &x.Netspeed }},
+	{ip2location.FieldIDDCode, func(x *ip2location.IP2Locationrecord) *string { return &x.Iddcode }},
+	{ip2location.FieldAreaCode, func(x *ip2location.IP2Locationrecord) *string { return &x.Areacode }},
+	{ip2location.FieldWeatherStationCode, func(x *ip2location.IP2Locationrecord) *string { return &x.Weatherstationcode }},
+	{ip2location.FieldWeatherStationName, func(x *ip2location.IP2Locationrecord) *string { return &x.Weatherstationname }},
+	{ip2location.FieldMCC, func(x *ip2location.IP2Locationrecord) *string { return &x.Mcc }},
+	{ip2location.FieldMNC, func(x *ip2location.IP2Locationrecord) *string { return &x.Mnc }},
+	{ip2location.FieldMobileBrand, func(x *ip2location.IP2Locationrecord) *string { return &x.Mobilebrand }},
+	{ip2location.FieldUsageType, func(x *ip2location.IP2Locationrecord) *string { return &x.Usagetype }},
+	{ip2location.FieldAddressType, func(x *ip2location.IP2Locationrecord) *string { return &x.Addresstype }},
+	{ip2location.FieldCategory, func(x *ip2location.IP2Locationrecord) *string { return &x.Category }},
+	{ip2location.FieldDistrict, func(x *ip2location.IP2Locationrecord) *string { return &x.District }},
+	{ip2location.FieldASN, func(x *ip2location.IP2Locationrecord) *string { return &x.Asn }},
+	{ip2location.FieldAS, func(x *ip2location.IP2Locationrecord) *string { return &x.As }},
+	{ip2location.FieldASDomain, func(x *ip2location.IP2Locationrecord) *string { return &x.Asdomain }},
+	{ip2location.FieldASUsageType, func(x *ip2location.IP2Locationrecord) *string { return &x.Asusagetype }},
+	{ip2location.FieldASCIDR, func(x *ip2location.IP2Locationrecord) *string { return &x.Ascidr }},
+}
+
+// returns the fields of the record asked for, limited to its Fields if set
+func maskrecord(rec *ip2location.IP2Locationrecord, fields ip2location.Field) ip2location.IP2Locationrecord {
+	if rec.Fields != 0 {
+		fields &= rec.Fields
+	} else {
+		fields &= ip2location.FieldAll
+	}
+
+	x := ip2location.IP2Locationrecord{Fields: fields}
+	for _, s := range fakestrings {
+		if fields&s.field != 0 {
+			*s.value(&x) = *s.value(rec)
+		}
+	}
+	if fields&ip2location.FieldLatitude != 0 {
+		x.Latitude = rec.Latitude
+	}
+	if fields&ip2location.FieldLongitude != 0 {
+		x.Longitude = rec.Longitude
+	}
+	if fields&ip2location.FieldElevation != 0 {
+		x.Elevation = rec.Elevation
+	}
+	return x
+}
@@ -0,0 +1,102 @@
+package ip2locationtest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+	"github.com/ip2location/ip2location-go/v9/ip2locationtest"
+)
+
+func TestFake(t *testing.T) {
+	var f ip2locationtest.Fake
+	office := ip2location.IP2Locationrecord{Country_short: "SG", City: "Office-Singapore", Latitude: 1.2897}
+	failure := errors.New("lookup failed")
+	f.Set("8.8.8.0/24", googlerec)
+	f.Set("8.8.8.8", office)
+	f.SetError("2001:db8::/32", failure)
+
+	want := googlerec
+	want.Fields = ip2location.FieldAll
+	if x, err := f.Get_all("8.8.8.1"); err != nil || x != want {
+		t.Errorf("Get_all(8.8.8.1) = %+v, %v, want %+v", x, err, want)
+	}
+	if x, err := f.Get_all("8.8.8.8"); err != nil || x.City != office.City {
+		t.Errorf("Get_all(8.8.8.8) = %+v, %v, want the more specific entry", x, err)
+	}
+	if x, err := f.Get_all("::ffff:8.8.8.8"); err != nil || x.City != office.City {
+		t.Errorf("Get_all(::ffff:8.8.8.8) = %+v, %v, want the entry for 8.8.8.8", x, err)
+	}
+	if _, err := f.Get_all("2001:db8::1"); err != failure {
+		t.Errorf("Get_all(2001:db8::1) error = %v, want the error set", err)
+	}
+	if _, err := f.Get_all("192.0.2.1"); err != ip2location.ErrNotFound {
+		t.Errorf("Get_all(192.0.2.1) error = %v, want ErrNotFound", err)
+	}
+	if _, err := f.Get_all("bad"); err != ip2location.ErrInvalidAddress {
+		t.Errorf("Get_all(bad) error = %v, want ErrInvalidAddress", err)
+	}
+
+	calls := f.Calls()
+	if len(calls) != 6 || calls[0] != (ip2locationtest.Call{IP: "8.8.8.1", Fields: ip2location.FieldAll}) || calls[5].IP != "bad" {
+		t.Errorf("Calls() = %+v", calls)
+	}
+}
+
+func TestFakeQueryFields(t *testing.T) {
+	var f ip2locationtest.Fake
+	f.Set("8.8.8.0/24", googlerec)
+
+	fields := ip2location.FieldCity | ip2location.FieldLatitude | ip2location.FieldASN
+	x, err := f.Query("8.8.8.8", fields)
+	want := ip2location.IP2Locationrecord{City: googlerec.City, Latitude: googlerec.Latitude, Asn: googlerec.Asn, Fields: fields}
+	if err != nil || x != want {
+		t.Errorf("Query(8.8.8.8, %v) = %+v, %v, want %+v", fields, x, err, want)
+	}
+
+	// a record with Fields acts as a database having only those fields
+	rec := googlerec
+	rec.Fields = ip2location.FieldCountryShort | ip2location.FieldCity
+	f.Set("8.8.8.8", rec)
+	x, err = f.Query("8.8.8.8", fields)
+	want = ip2location.IP2Locationrecord{City: googlerec.City, Fields: ip2location.FieldCity}
+	if err != nil || x != want {
+		t.Errorf("Query(8.8.8.8, %v) = %+v, %v, want %+v", fields, x, err, want)
+	}
+
+	// as from a DB, asking only for fields the record does not have is an error
+	for _, fields := range []ip2location.Field{ip2location.FieldISP, ip2location.FieldLatitude | ip2location.FieldElevation} {
+		if x, err := f.Query("8.8.8.8", fields); err != ip2location.ErrFieldNotSupported || x != (ip2location.IP2Locationrecord{}) {
+			t.Errorf("Query(8.8.8.8, %v) = %+v, %v, want ErrFieldNotSupported", fields, x, err)
+		}
+	}
+	db, err := ip2locationtest.OpenDB(3, ip2locationtest.Range{From: "8.8.8.0", To: "8.8.8.255", Record: googlerec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Query("8.8.8.8", ip2location.FieldISP); err != ip2location.ErrFieldNotSupported {
+		t.Errorf("DB3 Query(8.8.8.8, FieldISP) = %v, want the error of the Fake", err)
+	}
+}
+
+func TestFakeIPv4MappedPrefix(t *testing.T) {
+	var f ip2locationtest.Fake
+	f.Set("::ffff:10.0.0.0/104", googlerec)
+
+	for _, ip := range []string{"10.1.2.3", "::ffff:10.1.2.3"} {
+		if x, err := f.Get_all(ip); err != nil || x.City != googlerec.City {
+			t.Errorf("Get_all(%s) = %+v, %v, want the entry for ::ffff:10.0.0.0/104", ip, x, err)
+		}
+	}
+	if _, err := f.Get_all("11.0.0.1"); err != ip2location.ErrNotFound {
+		t.Errorf("Get_all(11.0.0.1) error = %v, want ErrNotFound", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Set of an IPv4-mapped prefix shorter than /96 did not panic")
+		}
+	}()
+	f.Set("::ffff:0.0.0.0/95", googlerec)
+}
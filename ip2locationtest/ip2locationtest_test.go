@@ -0,0 +1,101 @@
+package ip2locationtest_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+	"github.com/ip2location/ip2location-go/v9/ip2locationtest"
+)
+
+var googlerec = ip2location.IP2Locationrecord{
+	Country_short: "US", Country_long: "United States of America", Region: "California", City: "Mountain View",
+	Isp: "Google LLC", Latitude: 37.40599, Longitude: -122.078514, Domain: "google.com", Zipcode: "94043",
+	Timezone: "-07:00", Netspeed: "T1", Iddcode: "1", Areacode: "650", Weatherstationcode: "USCA0746",
+	Weatherstationname: "Mountain View", Mcc: "-", Mnc: "-", Mobilebrand: "-", Elevation: 32, Usagetype: "DCH",
+	Addresstype: "A", Category: "IAB19-11", District: "Santa Clara County", Asn: "15169", As: "Google LLC",
+	Asdomain: "google.com", Asusagetype: "DCH", Ascidr: "8.8.8.0/24",
+}
+
+var ranges = []ip2locationtest.Range{
+	{From: "8.8.8.0", To: "8.8.8.255", Record: googlerec},
+	{From: "2001:db8::", To: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", Record: googlerec},
+}
+
+func TestOpenDB(t *testing.T) {
+	for dbt := 1; dbt <= 26; dbt++ {
+		db, err := ip2locationtest.OpenDB(dbt, ranges...)
+		if err != nil {
+			t.Fatalf("DB%d: %v", dbt, err)
+		}
+		if v := db.PackageVersion(); v != strconv.Itoa(dbt) {
+			t.Errorf("DB%d: PackageVersion() = %s", dbt, v)
+		}
+		if v := db.DatabaseVersion(); v != "2024.1.1" {
+			t.Errorf("DB%d: DatabaseVersion() = %s, want the date of ip2locationtest.Date", dbt, v)
+		}
+
+		for _, ip := range []string{"8.8.8.8", "2001:db8::1"} {
+			x, err := db.Get_all(ip)
+			if err != nil {
+				t.Fatalf("DB%d: Get_all(%s): %v", dbt, ip, err)
+			}
+			if x.Fields != db.SupportedFields() || x.Country_short != "US" {
+				t.Errorf("DB%d: Get_all(%s) = %+v, want the fields %v of the range", dbt, ip, x, db.SupportedFields())
+			}
+		}
+		x, err := db.Get_all("192.0.2.1")
+		if err != nil || x.Country_short != "-" {
+			t.Errorf("DB%d: Get_all outside the ranges = %+v, %v, want the fields set to -", dbt, x, err)
+		}
+		db.Close()
+	}
+}
+
+func TestOpenDBFields(t *testing.T) {
+	db, err := ip2locationtest.OpenDB(26, ranges...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	want := googlerec
+	want.Fields = ip2location.FieldAll
+	if x, err := db.Get_all("8.8.8.8"); err != nil || x != want {
+		t.Errorf("Get_all(8.8.8.8) = %+v, %v, want %+v", x, err, want)
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	r, err := ip2locationtest.NewReader(3, ranges...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := ip2location.OpenDBWithReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	x, err := db.Get_all("8.8.8.8")
+	if err != nil || x.City != googlerec.City || x.Isp != "" {
+		t.Errorf("Get_all(8.8.8.8) = %+v, %v, want the DB3 fields of the range", x, err)
+	}
+	if r := db.Validate(); !r.OK() {
+		t.Errorf("Validate: %s", r)
+	}
+}
+
+func TestBuildInvalidRanges(t *testing.T) {
+	for _, rs := range [][]ip2locationtest.Range{
+		{{From: "8.8.8.0", To: "8.8.8.255"}, {From: "8.8.8.128", To: "8.8.9.0"}},
+		{{From: "8.8.8.0", To: "bad"}},
+	} {
+		if _, err := ip2locationtest.Build(1, rs...); err == nil {
+			t.Errorf("Build(%+v) succeeded", rs)
+		}
+	}
+	if _, err := ip2locationtest.Build(0, ranges...); err == nil {
+		t.Error("Build of DB0 succeeded")
+	}
+}
@@ -0,0 +1,152 @@
+package ip2location
+
+import (
+	"context"
+	"encoding/binary"
+	"net/netip"
+	"strings"
+
+	"lukechampine.com/uint128"
+)
+
+// names of the fields in bit order
+var fieldnames = [...]string{"country_short", "country_long", "region", "city", "isp", "latitude", "longitude", "domain", "zipcode", "timezone", "netspeed", "iddcode", "areacode", "weatherstationcode", "weatherstationname", "mcc", "mnc", "mobilebrand", "elevation", "usagetype", "addresstype", "category", "district", "asn", "as", "asdomain", "asusagetype", "ascidr"}
+
+// String returns the names of the selected fields separated by "|".
+func (f Field) String() string {
+	var names []string
+	for i, name := range fieldnames {
+		if f&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Querier is implemented by DB, ReloadableDB, Overlay and MultiDB, so that code doing lookups can take any
+// of them, or a fake such as ip2locationtest.Fake in tests.
+type Querier interface {
+	Get_all(ipaddress string) (IP2Locationrecord, error)
+	Query(ipaddress string, fields Field) (IP2Locationrecord, error)
+}
+
+var (
+	_ Querier = (*DB)(nil)
+	_ Querier = (*ReloadableDB)(nil)
+	_ Querier = (*Overlay)(nil)
+	_ Querier = (*MultiDB)(nil)
+)
+
+// Query will return the selected geolocation fields based on the queried IP address.
+// The database is searched once and only the selected columns are decoded.
+func (d *DB) Query(ipaddress string, fields Field) (IP2Locationrecord, error) {
+	return d.query(ipaddress, fields)
+}
+
+// QueryAddr is like Query but takes a parsed IP address.
+func (d *DB) QueryAddr(addr netip.Addr, fields Field) (IP2Locationrecord, error) {
+	iptype, ipno, ipindex := d.checkaddr(addr)
+	return d.querynum(iptype, ipno, ipindex, fields)
+}
+
+// readers that can stop waiting for data once a context is done, such as HTTPRangeReader
+type contextreader interface {
+	ReadAtContext(ctx context.Context, p []byte, off int64) (int, error)
+}
+
+// DBReader reading through a contextreader with a fixed context
+type ctxreader struct {
+	r   DBReader
+	cr  contextreader
+	ctx context.Context
+}
+
+func (c *ctxreader) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func (c *ctxreader) ReadAt(p []byte, off int64) (int, error) {
+	return c.cr.ReadAtContext(c.ctx, p, off)
+}
+
+// closing the view must not close the shared reader
+func (c *ctxreader) Close() error {
+	return nil
+}
+
+// WithContext returns a DB sharing the BIN database of d whose reads give up once ctx is done, for readers that
+// support it such as HTTPRangeReader. For other readers d itself is returned. The returned DB must not be closed.
+func (d *DB) WithContext(ctx context.Context) *DB {
+	cr, ok := d.f.(contextreader)
+	if !ok {
+		return d
+	}
+	d2 := *d
+	d2.f = &ctxreader{r: d.f, cr: cr, ctx: ctx}
+	return &d2
+}
+
+// QueryContext is like Query but gives up once ctx is done, returning the context error.
+// Only readers such as HTTPRangeReader can be interrupted in the middle of a lookup.
+func (d *DB) QueryContext(ctx context.Context, ipaddress string, fields Field) (IP2Locationrecord, error) {
+	if err := ctx.Err(); err != nil {
+		return IP2Locationrecord{}, err
+	}
+	return d.WithContext(ctx).Query(ipaddress, fields)
+}
+
+// LookupAddr will return all geolocation fields based on the queried IP address.
+// It gives the same results as Get_all without formatting and parsing the address as a string.
+func (d *DB) LookupAddr(addr netip.Addr) (IP2Locationrecord, error) {
+	iptype, ipno, ipindex := d.checkaddr(addr)
+	return d.querynum(iptype, ipno, ipindex, FieldAll)
+}
+
+// LookupIPv4Number will return all geolocation fields based on the queried IPv4 number,
+// as found in the ip_from and ip_to columns of the IP2Location CSV files.
+func (d *DB) LookupIPv4Number(ipnum uint32) (IP2Locationrecord, error) {
+	iptype, ipno, ipindex := d.checkipnum(4, uint128.From64(uint64(ipnum)))
+	return d.querynum(iptype, ipno, ipindex, FieldAll)
+}
+
+// LookupIPv6Number will return all geolocation fields based on the queried IPv6 number,
+// as found in the ip_from and ip_to columns of the IP2Location CSV files.
+// IPv4-mapped, 6to4 and Teredo numbers are looked up in the IPv4 data as Get_all does, following the MappingPolicy.
+// Other numbers give ErrIPv6NotSupported from a database without IPv6 data, even those below 2^32.
+func (d *DB) LookupIPv6Number(ipnum uint128.Uint128) (IP2Locationrecord, error) {
+	iptype, ipno, ipindex := d.checkipnum(6, ipnum)
+	return d.querynum(iptype, ipno, ipindex, FieldAll)
+}
+
+// get IP type and calculate IP number from a parsed address
+func (d *DB) checkaddr(addr netip.Addr) (uint32, uint128.Uint128, uint32) {
+	return d.checkipnum(addrtonum(addr))
+}
+
+// convert address to IP type and IP number
+func addrtonum(addr netip.Addr) (uint32, uint128.Uint128) {
+	if addr.Is4() {
+		b := addr.As4()
+		return 4, uint128.From64(uint64(binary.BigEndian.Uint32(b[:])))
+	}
+	if addr.Is6() {
+		b := addr.As16()
+		return 6, uint128.New(binary.BigEndian.Uint64(b[8:]), binary.BigEndian.Uint64(b[:8]))
+	}
+	return 0, uint128.From64(0)
+}
+
+// LookupInto fills the supplied record with all geolocation fields based on the queried IP address.
+// It gives the same results as Get_all but reuses internal buffers, so a caller that keeps reusing the
+// same record does not allocate apart from the strings read from file. With OpenDBInMemory the strings
+// point into the loaded file and the lookup does not allocate at all.
+func (d *DB) LookupInto(ipaddress string, x *IP2Locationrecord) error {
+	iptype, ipno, ipindex := d.checkip(ipaddress)
+	return d.queryinto(iptype, ipno, ipindex, FieldAll, x, nil)
+}
+
+// LookupAddrInto is like LookupInto but takes a parsed IP address.
+func (d *DB) LookupAddrInto(addr netip.Addr, x *IP2Locationrecord) error {
+	iptype, ipno, ipindex := d.checkaddr(addr)
+	return d.queryinto(iptype, ipno, ipindex, FieldAll, x, nil)
+}
@@ -0,0 +1,147 @@
+package ip2location_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+	"github.com/ip2location/ip2location-go/v9/ip2locationtest"
+	"lukechampine.com/uint128"
+)
+
+func TestLookupInto(t *testing.T) {
+	db := opentestdb(t)
+
+	for _, ip := range []string{"8.8.8.8", "10.1.2.3", "2001:db8::1", "192.0.2.1"} {
+		want, err := db.Get_all(ip)
+		if err != nil {
+			t.Fatalf("Get_all(%s): %v", ip, err)
+		}
+		var x ip2location.IP2Locationrecord
+		if err := db.LookupInto(ip, &x); err != nil {
+			t.Fatalf("LookupInto(%s): %v", ip, err)
+		}
+		if x != want {
+			t.Errorf("LookupInto(%s) = %+v, want %+v", ip, x, want)
+		}
+		if err := db.LookupAddrInto(netip.MustParseAddr(ip), &x); err != nil || x != want {
+			t.Errorf("LookupAddrInto(%s) = %+v, %v, want %+v", ip, x, err, want)
+		}
+	}
+
+	var x ip2location.IP2Locationrecord
+	if err := db.LookupInto("bad", &x); err != ip2location.ErrInvalidAddress {
+		t.Errorf("LookupInto(bad) error = %v, want ErrInvalidAddress", err)
+	}
+}
+
+// returns the IPv6 number of an address as in the IP2Location CSV files, IPv4 addresses being IPv4-mapped
+func ipv6number(ip string) uint128.Uint128 {
+	b := netip.MustParseAddr(ip).As16()
+	return uint128.New(binary.BigEndian.Uint64(b[8:]), binary.BigEndian.Uint64(b[:8]))
+}
+
+func TestLookupIPNumber(t *testing.T) {
+	db := opentestdb(t)
+
+	// the first and last numbers, the edges of a range and of the gaps around it
+	for _, ip := range []string{"0.0.0.0", "8.8.7.255", "8.8.8.0", "8.8.8.255", "8.8.9.0", "255.255.255.255"} {
+		want, wanterr := db.Get_all(ip)
+		num := uint32(ipv6number(ip).Lo)
+		if x, err := db.LookupIPv4Number(num); x != want || err != wanterr {
+			t.Errorf("LookupIPv4Number(%d) = %+v, %v, want %+v, %v", num, x, err, want, wanterr)
+		}
+	}
+
+	// numbers of 2^32 and above are IPv6 addresses, unless they are IPv4-mapped, 6to4 or Teredo ones
+	for _, ip := range []string{"::", "::255.255.255.255", "::1:0:0", "::ffff:0.0.0.0", "::ffff:8.8.8.8", "::ffff:255.255.255.255",
+		"::1:0:0:0", "2002:808:808::1", "2001:db7:ffff:ffff:ffff:ffff:ffff:ffff", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff",
+		"2001:db9::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"} {
+		want, wanterr := db.Get_all(ip)
+		num := ipv6number(ip)
+		if x, err := db.LookupIPv6Number(num); x != want || err != wanterr {
+			t.Errorf("LookupIPv6Number(%s) = %+v, %v, want %+v, %v", num, x, err, want, wanterr)
+		}
+	}
+	if x, err := db.LookupIPv6Number(uint128.From64(1 << 32)); err != nil || x.Country_short != "-" {
+		t.Errorf("LookupIPv6Number(2^32) = %+v, %v, want the empty range of the IPv6 data", x, err)
+	}
+	if x, err := db.LookupIPv6Number(ipv6number("::ffff:8.8.8.8")); err != nil || x.City != googlerec.City {
+		t.Errorf("LookupIPv6Number of ::ffff:8.8.8.8 = %+v, %v, want %s", x, err, googlerec.City)
+	}
+
+	// IPv6 numbers are out of range of a database without IPv6 data, IPv4-mapped ones are not
+	data, err := ip2locationtest.Build(3, testranges[:2]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ipv4only, err := ip2location.OpenDBWithBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ipv4only.Close()
+	for _, num := range []uint128.Uint128{uint128.Zero, ipv6number("::8.8.8.8"), uint128.From64(1 << 32), ipv6number("2001:db8::1"), uint128.Max} {
+		if _, err := ipv4only.LookupIPv6Number(num); !errors.Is(err, ip2location.ErrIPv6NotSupported) {
+			t.Errorf("LookupIPv6Number(%s) without IPv6 data = %v, want ErrIPv6NotSupported", num, err)
+		}
+	}
+	if x, err := ipv4only.LookupIPv6Number(ipv6number("::ffff:8.8.8.8")); err != nil || x.City != googlerec.City {
+		t.Errorf("LookupIPv6Number of ::ffff:8.8.8.8 without IPv6 data = %+v, %v, want %s", x, err, googlerec.City)
+	}
+	if x, err := ipv4only.LookupIPv4Number(^uint32(0)); err != nil || x.Country_short != "-" {
+		t.Errorf("LookupIPv4Number(2^32-1) without IPv6 data = %+v, %v", x, err)
+	}
+}
+
+func TestLookupIntoInMemoryDoesNotAllocate(t *testing.T) {
+	if raceenabled {
+		t.Skip("allocations are not counted with the race detector")
+	}
+	db := opentestdb(t)
+
+	var x ip2location.IP2Locationrecord
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = db.LookupInto("8.8.8.8", &x)
+		_ = db.LookupInto("2001:db8::1", &x)
+	})
+	if allocs != 0 {
+		t.Errorf("LookupInto allocates %v times on a database in memory, want 0", allocs)
+	}
+}
+
+func benchmarklookupinto(b *testing.B, db *ip2location.DB) {
+	ips := []string{"8.8.8.8", "10.1.2.3", "2001:db8::1", "192.0.2.1"}
+	var x ip2location.IP2Locationrecord
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.LookupInto(ips[i%len(ips)], &x); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLookupInto(b *testing.B) {
+	data := testbin(b)
+
+	b.Run("File", func(b *testing.B) {
+		db, err := ip2location.OpenDB(writebin(b, data))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer db.Close()
+		benchmarklookupinto(b, db)
+	})
+
+	b.Run("Memory", func(b *testing.B) {
+		db, err := ip2location.OpenDBWithBytes(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer db.Close()
+		benchmarklookupinto(b, db)
+	})
+}
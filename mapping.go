@@ -0,0 +1,124 @@
+package ip2location
+
+import (
+	"errors"
+	"net/netip"
+	"sort"
+
+	"lukechampine.com/uint128"
+)
+
+const invalid_nat64_prefix string = "NAT64 prefixes must be IPv6 prefixes of length 32, 40, 48, 56, 64 or 96."
+
+// Mapping tells how an IPv6 address was mapped to the IPv4 address it was looked up as.
+type Mapping uint8
+
+// Mappings of IPv6 transition addresses to IPv4.
+const (
+	MappingNone       Mapping = iota // looked up as given
+	MappingIPv4Mapped                // ::ffff:0:0/96
+	Mapping6to4                      // 2002::/16
+	MappingTeredo                    // 2001::/32
+	MappingNAT64                     // one of the NAT64 prefixes of the MappingPolicy
+)
+
+var mappingnames = [...]string{"none", "ipv4-mapped", "6to4", "teredo", "nat64"}
+
+// String returns the name of the mapping.
+func (m Mapping) String() string {
+	if int(m) < len(mappingnames) {
+		return mappingnames[m]
+	}
+	return "unknown"
+}
+
+// NAT64WellKnownPrefix is the well-known prefix of RFC 6052, to be added to MappingPolicy.NAT64.
+var NAT64WellKnownPrefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// The MappingPolicy struct selects which IPv6 transition addresses are looked up in the IPv4 data, using the
+// IPv4 address they embed. The zero value keeps the default behaviour of mapping IPv4-mapped, 6to4 and Teredo
+// addresses. Addresses in a disabled range are looked up in the IPv6 data like any other IPv6 address.
+type MappingPolicy struct {
+	DisableIPv4Mapped bool
+	Disable6to4       bool
+	DisableTeredo     bool
+
+	// NAT64 lists the prefixes of the NAT64 gateways, such as NAT64WellKnownPrefix or the /96 of an operator.
+	// The IPv4 address is embedded as described in RFC 6052, which allows prefixes of length 32, 40, 48, 56,
+	// 64 and 96. NAT64 prefixes are checked before the built-in mappings, the most specific first.
+	NAT64 []netip.Prefix
+}
+
+// SetMappingPolicy changes which IPv6 transition addresses are looked up in the IPv4 data.
+// It must be called before the DB is used for lookups.
+func (d *DB) SetMappingPolicy(p MappingPolicy) error {
+	nat64 := make([]netip.Prefix, 0, len(p.NAT64))
+	for _, pfx := range p.NAT64 {
+		if !pfx.IsValid() || !pfx.Addr().Is6() || pfx.Addr().Is4In6() {
+			return errors.New(invalid_nat64_prefix)
+		}
+		switch pfx.Bits() {
+		case 32, 40, 48, 56, 64, 96:
+		default:
+			return errors.New(invalid_nat64_prefix)
+		}
+		nat64 = append(nat64, pfx.Masked())
+	}
+	sort.SliceStable(nat64, func(i, j int) bool { return nat64[i].Bits() > nat64[j].Bits() })
+	p.NAT64 = nat64
+	d.mapping = p
+	return nil
+}
+
+// MappingPolicy returns the policy set with SetMappingPolicy.
+func (d *DB) MappingPolicy() MappingPolicy {
+	p := d.mapping
+	p.NAT64 = append([]netip.Prefix(nil), p.NAT64...)
+	return p
+}
+
+// remap IPv6 transition addresses to IPv4 as allowed by the mapping policy
+func (d *DB) mapipnum(iptype uint32, ipnum uint128.Uint128) (uint32, uint128.Uint128, Mapping) {
+	if iptype != 6 {
+		return iptype, ipnum, MappingNone
+	}
+
+	if len(d.mapping.NAT64) > 0 {
+		addr := numtoaddr(6, ipnum)
+		for _, pfx := range d.mapping.NAT64 {
+			if pfx.Contains(addr) {
+				return 4, nat64ipv4(addr, pfx.Bits()), MappingNAT64
+			}
+		}
+	}
+
+	if !d.mapping.DisableIPv4Mapped && ipnum.Cmp(from_v4mapped) >= 0 && ipnum.Cmp(to_v4mapped) <= 0 {
+		// ipv4-mapped ipv6 should treat as ipv4 and read ipv4 data section
+		return 4, ipnum.Sub(from_v4mapped), MappingIPv4Mapped
+	}
+	if !d.mapping.Disable6to4 && ipnum.Cmp(from_6to4) >= 0 && ipnum.Cmp(to_6to4) <= 0 {
+		// 6to4 so need to remap to ipv4
+		return 4, ipnum.Rsh(80).And(last_32bits), Mapping6to4
+	}
+	if !d.mapping.DisableTeredo && ipnum.Cmp(from_teredo) >= 0 && ipnum.Cmp(to_teredo) <= 0 {
+		// Teredo so need to remap to ipv4
+		ipnum = uint128.Uint128{Lo: ^ipnum.Lo, Hi: ^ipnum.Hi}
+		return 4, ipnum.And(last_32bits), MappingTeredo
+	}
+	return iptype, ipnum, MappingNone
+}
+
+// extract the IPv4 address embedded after a NAT64 prefix, skipping bits 64 to 71 as RFC 6052 requires
+func nat64ipv4(addr netip.Addr, bits int) uint128.Uint128 {
+	b := addr.As16()
+	var v uint64
+	n := 0
+	for i := bits / 8; n < 4; i++ {
+		if i == 8 {
+			continue
+		}
+		v = v<<8 | uint64(b[i])
+		n++
+	}
+	return uint128.From64(v)
+}
@@ -0,0 +1,60 @@
+package ip2location
+
+import (
+	"bytes"
+	"io/ioutil"
+)
+
+// memoryReader is a DBReader over a BIN file that is held entirely in memory,
+// either loaded into a byte slice or memory-mapped read-only.
+type memoryReader struct {
+	*bytes.Reader
+	data []byte
+	mmap bool
+}
+
+func newMemoryReader(data []byte, mmap bool) *memoryReader {
+	return &memoryReader{Reader: bytes.NewReader(data), data: data, mmap: mmap}
+}
+
+// Close releases the memory held by the reader, unmapping it if it was memory-mapped.
+func (m *memoryReader) Close() error {
+	data := m.data
+	m.data = nil
+	m.Reader = bytes.NewReader(nil)
+
+	if m.mmap && data != nil {
+		return munmap(data)
+	}
+	return nil
+}
+
+// OpenDBInMemory takes the path to the IP2Location BIN database file and loads the whole file into memory.
+// Lookups are then served straight from memory without any file reads.
+func OpenDBInMemory(dbpath string) (*DB, error) {
+	data, err := ioutil.ReadFile(dbpath)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenDBWithBytes(data)
+}
+
+// OpenDBWithBytes takes the full content of an IP2Location BIN database file. The slice is used as is
+// and must not be modified while the DB is open.
+func OpenDBWithBytes(data []byte) (*DB, error) {
+	return OpenDBWithReader(newMemoryReader(data, false))
+}
+
+// OpenDBMmap takes the path to the IP2Location BIN database file and memory-maps it read-only.
+// Lookups are then served straight from the mapping without any file reads. The file must not be
+// modified in place while it is mapped, replace it by renaming a new file over it instead.
+// On platforms without mmap support the file is loaded into memory as in OpenDBInMemory.
+func OpenDBMmap(dbpath string) (*DB, error) {
+	data, mapped, err := mmapFile(dbpath)
+	if err != nil {
+		return nil, err
+	}
+
+	return OpenDBWithReader(newMemoryReader(data, mapped))
+}
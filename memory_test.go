@@ -0,0 +1,112 @@
+package ip2location_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// returns whether the file is mapped into the process, or false where this cannot be told
+func mapped(path string) bool {
+	maps, err := os.ReadFile("/proc/self/maps")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(string(maps), path)
+}
+
+// runs the lookups of the fixtures through every way of opening a DB and compares them with OpenDB
+func TestOpenModes(t *testing.T) {
+	data := testbin(t)
+	path := writebin(t, data)
+	zippath := filepath.Join(t.TempDir(), "IP2LOCATION.ZIP")
+	if err := os.WriteFile(zippath, zipbin(t, data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var requests int64
+	srv := rangeserver(t, data, &requests)
+
+	want, err := ip2location.OpenDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer want.Close()
+
+	for _, tc := range []struct {
+		name string
+		open func() (*ip2location.DB, error)
+	}{
+		{"OpenDB", func() (*ip2location.DB, error) { return ip2location.OpenDB(path) }},
+		{"OpenDBInMemory", func() (*ip2location.DB, error) { return ip2location.OpenDBInMemory(path) }},
+		{"OpenDBMmap", func() (*ip2location.DB, error) { return ip2location.OpenDBMmap(path) }},
+		{"OpenDBWithBytes", func() (*ip2location.DB, error) { return ip2location.OpenDBWithBytes(data) }},
+		{"OpenDBWithReader", func() (*ip2location.DB, error) {
+			return ip2location.OpenDBWithReader(bytesreader{bytes.NewReader(data)})
+		}},
+		{"OpenDBFromZip", func() (*ip2location.DB, error) { return ip2location.OpenDBFromZip(zippath) }},
+		{"OpenDBFS", func() (*ip2location.DB, error) {
+			return ip2location.OpenDBFS(os.DirFS(filepath.Dir(path)), filepath.Base(path))
+		}},
+		{"NewHTTPRangeReader", func() (*ip2location.DB, error) {
+			r, err := ip2location.NewHTTPRangeReader(context.Background(), srv.URL, ip2location.HTTPRangeReaderOptions{BlockSize: 4096})
+			if err != nil {
+				return nil, err
+			}
+			return ip2location.OpenDBWithReader(r)
+		}},
+	} {
+		db, err := tc.open()
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if got := db.DatabaseVersion(); got != want.DatabaseVersion() {
+			t.Errorf("%s: DatabaseVersion() = %s, want %s", tc.name, got, want.DatabaseVersion())
+		}
+		for _, fields := range cachefields {
+			for _, ip := range cacheips {
+				wantx, wanterr := want.Lookup(ip, fields)
+				x, err := db.Lookup(ip, fields)
+				if err != wanterr || !reflect.DeepEqual(x, wantx) {
+					t.Errorf("%s: Lookup(%s, %v) = %+v, %v, want %+v, %v", tc.name, ip, fields, x, err, wantx, wanterr)
+				}
+			}
+		}
+		db.Close()
+	}
+}
+
+func TestOpenDBMmapCloseUnmaps(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the mappings are read from /proc/self/maps")
+	}
+	path := writebin(t, testbin(t))
+
+	db, err := ip2location.OpenDBMmap(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !mapped(path) {
+		t.Fatalf("%s is not mapped after OpenDBMmap", path)
+	}
+	x, err := db.Get_all("8.8.8.8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.Close()
+	if mapped(path) {
+		t.Errorf("%s is still mapped after Close", path)
+	}
+	// strings read from the mapping stay valid
+	if x.City != googlerec.City {
+		t.Errorf("City after Close = %q, want %q", x.City, googlerec.City)
+	}
+}
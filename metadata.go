@@ -0,0 +1,71 @@
+package ip2location
+
+import (
+	"strconv"
+	"time"
+)
+
+// Editions of the IP2Location databases, as given by the product type in the header.
+const (
+	EditionLITE       = "LITE"
+	EditionCommercial = "Commercial"
+)
+
+// The Metadata struct stores the header information of the opened BIN database.
+type Metadata struct {
+	DatabaseType int       // database type, from 1 to 26
+	Product      string    // product name such as "DB11"
+	Columns      int       // number of columns in each row, including IP From
+	Date         time.Time // build date of the database, in UTC
+	IPv4Count    uint32    // number of IPv4 rows
+	IPv6Count    uint32    // number of IPv6 rows, 0 for IPv4 only databases
+	IPv4Indexed  bool      // whether the IPv4 rows have a /16 index
+	IPv6Indexed  bool      // whether the IPv6 rows have an index
+	ProductCode  uint8     // 1 for the IP2Location databases, only set since January 2021
+	ProductType  uint8     // only set since January 2021
+	FileSize     uint32    // size of the BIN file given by the header
+	Fields       Field     // fields the database type supports
+
+	// Edition is EditionLITE or EditionCommercial, or empty if the header does not tell.
+	Edition string
+}
+
+// Metadata returns the header information of the BIN database, all zero if it could not be read.
+func (d *DB) Metadata() Metadata {
+	if !d.metaok {
+		return Metadata{}
+	}
+
+	md := Metadata{
+		DatabaseType: int(d.meta.databasetype),
+		Product:      "DB" + strconv.Itoa(int(d.meta.databasetype)),
+		Columns:      int(d.meta.databasecolumn),
+		Date:         time.Date(2000+int(d.meta.databaseyear), time.Month(d.meta.databasemonth), int(d.meta.databaseday), 0, 0, 0, 0, time.UTC),
+		IPv4Count:    d.meta.ipv4databasecount,
+		IPv6Count:    d.meta.ipv6databasecount,
+		IPv4Indexed:  d.meta.ipv4indexed,
+		IPv6Indexed:  d.meta.ipv6indexed,
+		ProductCode:  d.meta.productcode,
+		ProductType:  d.meta.producttype,
+		FileSize:     d.meta.filesize,
+		Fields:       d.fields,
+	}
+
+	switch d.meta.producttype {
+	case 1:
+		md.Edition = EditionLITE
+	case 2:
+		md.Edition = EditionCommercial
+	}
+	return md
+}
+
+// Metadata returns the header information of the current DB.
+func (r *ReloadableDB) Metadata() Metadata {
+	var md Metadata
+	_ = r.Do(func(db *DB) error {
+		md = db.Metadata()
+		return nil
+	})
+	return md
+}
@@ -0,0 +1,18 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package ip2location
+
+import (
+	"io/ioutil"
+)
+
+// mmapFile falls back to loading the whole file into memory where mmap is not available.
+func mmapFile(dbpath string) ([]byte, bool, error) {
+	data, err := ioutil.ReadFile(dbpath)
+	return data, false, err
+}
+
+func munmap(data []byte) error {
+	return nil
+}
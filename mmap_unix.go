@@ -0,0 +1,43 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package ip2location
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// mmapFile maps the whole file read-only into memory.
+func mmapFile(dbpath string) ([]byte, bool, error) {
+	f, err := os.Open(dbpath)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, false, err
+	}
+
+	size := fi.Size()
+	if size == 0 {
+		return []byte{}, false, nil // empty files cannot be mapped
+	}
+	if int64(int(size)) != size {
+		return nil, false, errors.New("The BIN file is too large to be memory-mapped.")
+	}
+
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,160 @@
+package ip2location
+
+import (
+	"errors"
+	"net/netip"
+)
+
+const not_in_multidb string = "The database is not part of the MultiDB."
+
+// The MultiResult struct stores a record merged from the databases of a MultiDB
+// together with the database that supplied each field.
+type MultiResult struct {
+	Record IP2Locationrecord
+
+	// Sources maps each field in Record.Fields to the index of the database it was read from,
+	// in the order the databases were passed to NewMultiDB.
+	Sources map[Field]int
+}
+
+// The MultiDB struct answers lookups from several BIN databases, such as a DB11 for the location
+// and a separate database for the ASN, taking each field from the first database that has it.
+type MultiDB struct {
+	dbs      []*DB
+	priority map[Field][]int // databases to read each field from, in order
+}
+
+// NewMultiDB returns a MultiDB over the opened databases. By default every field is read from the
+// first database in the argument order that supports it, use SetPriority to change that.
+func NewMultiDB(dbs ...*DB) *MultiDB {
+	return &MultiDB{dbs: dbs, priority: make(map[Field][]int)}
+}
+
+// SetPriority sets the databases to read the fields from, in order. Databases left out are not used
+// for those fields. SetPriority must be called before the MultiDB is used for lookups.
+func (m *MultiDB) SetPriority(fields Field, dbs ...*DB) error {
+	order := make([]int, 0, len(dbs))
+	for _, db := range dbs {
+		i := m.indexof(db)
+		if i < 0 {
+			return errors.New(not_in_multidb)
+		}
+		order = append(order, i)
+	}
+
+	for f := Field(1); f&FieldAll != 0; f <<= 1 {
+		if fields&f != 0 {
+			m.priority[f] = order
+		}
+	}
+	return nil
+}
+
+func (m *MultiDB) indexof(db *DB) int {
+	for i, d := range m.dbs {
+		if d == db {
+			return i
+		}
+	}
+	return -1
+}
+
+// returns the first database for the field that supports it and has not failed, -1 if there is none
+func (m *MultiDB) source(f Field, failed []bool) int {
+	order, ok := m.priority[f]
+	if !ok {
+		for i, db := range m.dbs {
+			if !failed[i] && db.SupportedFields()&f != 0 {
+				return i
+			}
+		}
+		return -1
+	}
+	for _, i := range order {
+		if !failed[i] && m.dbs[i].SupportedFields()&f != 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get_all will return all geolocation fields based on the queried IP address, merged from the databases.
+func (m *MultiDB) Get_all(ipaddress string) (IP2Locationrecord, error) {
+	res, err := m.Lookup(ipaddress, FieldAll)
+	return res.Record, err
+}
+
+// Query will return the selected geolocation fields based on the queried IP address, merged from the databases.
+func (m *MultiDB) Query(ipaddress string, fields Field) (IP2Locationrecord, error) {
+	res, err := m.Lookup(ipaddress, fields)
+	return res.Record, err
+}
+
+// Lookup will return the selected geolocation fields based on the queried IP address
+// together with the database that supplied each field.
+func (m *MultiDB) Lookup(ipaddress string, fields Field) (MultiResult, error) {
+	addr, err := netip.ParseAddr(ipaddress)
+	if err != nil || addr.Zone() != "" {
+		return MultiResult{}, ErrInvalidAddress
+	}
+	return m.LookupAddr(addr, fields)
+}
+
+// LookupAddr is like Lookup but takes a parsed IP address. Each database is queried at most once.
+// If a database has no record for the IP address, for example an IPv4 BIN queried with an IPv6 address,
+// its fields are read from the next database in the priority order instead. Other errors, such as read
+// errors, are returned at once rather than hidden behind the next database.
+func (m *MultiDB) LookupAddr(addr netip.Addr, fields Field) (MultiResult, error) {
+	res := MultiResult{Sources: make(map[Field]int)}
+	failed := make([]bool, len(m.dbs))
+	pending := fields & FieldAll
+	var lasterr error
+	answered := false
+
+	for pending != 0 {
+		// group the fields still missing by the database to read them from, queried in database order
+		groups := make([]Field, len(m.dbs))
+		for f := Field(1); f <= pending; f <<= 1 {
+			if pending&f == 0 {
+				continue
+			}
+			if i := m.source(f, failed); i >= 0 {
+				groups[i] |= f
+			} else {
+				pending &^= f
+			}
+		}
+
+		for i, set := range groups {
+			if set == 0 {
+				continue
+			}
+			x, err := m.dbs[i].QueryAddr(addr, set)
+			if err != nil {
+				if !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrIPv6NotSupported) && !errors.Is(err, ErrFieldNotSupported) {
+					return MultiResult{}, err
+				}
+				failed[i] = true
+				lasterr = err
+				continue
+			}
+			answered = true
+			found := set & x.Fields // without an elevation the database has as "-"
+			copyfields(&res.Record, &x, found)
+			for f := Field(1); f <= found; f <<= 1 {
+				if found&f != 0 {
+					res.Sources[f] = i
+				}
+			}
+			pending &^= set
+		}
+	}
+
+	if !answered {
+		if lasterr == nil {
+			lasterr = ErrFieldNotSupported
+		}
+		return res, lasterr
+	}
+	return res, nil
+}
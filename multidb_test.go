@@ -0,0 +1,98 @@
+package ip2location_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+	"github.com/ip2location/ip2location-go/v9/ip2locationtest"
+)
+
+// returns a DB3 without IPv6 data and the DB26 of the test ranges
+func multidbs(t *testing.T) (*ip2location.DB, *ip2location.DB) {
+	t.Helper()
+
+	db3, err := ip2locationtest.OpenDB(3, testranges[:2]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db3.Close)
+	return db3, opentestdb(t)
+}
+
+func TestMultiDB(t *testing.T) {
+	db3, db26 := multidbs(t)
+	m := ip2location.NewMultiDB(db3, db26)
+
+	res, err := m.Lookup("8.8.8.8", ip2location.FieldAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := googlerec
+	want.Fields = ip2location.FieldAll
+	if res.Record != want {
+		t.Errorf("Lookup(8.8.8.8) = %+v, want %+v", res.Record, want)
+	}
+	for f, src := range map[ip2location.Field]int{ip2location.FieldCountryShort: 0, ip2location.FieldCity: 0, ip2location.FieldASN: 1, ip2location.FieldElevation: 1} {
+		if res.Sources[f] != src {
+			t.Errorf("Sources[%v] = %d, want %d", f, res.Sources[f], src)
+		}
+	}
+	if len(res.Sources) != 28 {
+		t.Errorf("Sources has %d fields, want 28", len(res.Sources))
+	}
+
+	// the DB3 has no IPv6 data, so every field comes from the DB26
+	res, err = m.Lookup("2001:db8::1", ip2location.FieldCity|ip2location.FieldASN)
+	if err != nil || res.Record.City != ipv6rec.City || res.Record.Asn != ipv6rec.Asn ||
+		res.Sources[ip2location.FieldCity] != 1 || res.Sources[ip2location.FieldASN] != 1 {
+		t.Errorf("Lookup(2001:db8::1) = %+v, %v, want the DB26 record", res, err)
+	}
+}
+
+func TestMultiDBSetPriority(t *testing.T) {
+	db3, db26 := multidbs(t)
+	m := ip2location.NewMultiDB(db3, db26)
+
+	if err := m.SetPriority(ip2location.FieldCity, db26, db3); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetPriority(ip2location.FieldASN, db3); err != nil { // the DB3 has no ASN
+		t.Fatal(err)
+	}
+	if err := m.SetPriority(ip2location.FieldCity, opentestdb(t)); err == nil {
+		t.Error("SetPriority with a DB outside the MultiDB succeeded")
+	}
+
+	res, err := m.Lookup("8.8.8.8", ip2location.FieldRegion|ip2location.FieldCity|ip2location.FieldASN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sources[ip2location.FieldCity] != 1 || res.Sources[ip2location.FieldRegion] != 0 {
+		t.Errorf("Sources = %v, want the city from the DB26 and the region from the DB3", res.Sources)
+	}
+	if _, ok := res.Sources[ip2location.FieldASN]; ok || res.Record.Fields&ip2location.FieldASN != 0 || res.Record.Asn != "" {
+		t.Errorf("Lookup(8.8.8.8) = %+v, want no ASN", res)
+	}
+
+	if _, err := m.Lookup("8.8.8.8", ip2location.FieldASN); err != ip2location.ErrFieldNotSupported {
+		t.Errorf("Lookup of the ASN only = %v, want ErrFieldNotSupported", err)
+	}
+}
+
+func TestMultiDBReturnsReadErrors(t *testing.T) {
+	broken, err := ip2location.OpenDB(writebin(t, testbin(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken.Close()
+	_, db26 := multidbs(t)
+	m := ip2location.NewMultiDB(broken, db26)
+
+	for i := 0; i < 10; i++ {
+		res, err := m.Lookup("8.8.8.8", ip2location.FieldCity)
+		if err == nil || errors.Is(err, ip2location.ErrNotFound) {
+			t.Fatalf("Lookup with a broken first DB = %+v, %v, want its read error", res, err)
+		}
+	}
+}
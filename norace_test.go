@@ -0,0 +1,5 @@
+//go:build !race
+
+package ip2location_test
+
+const raceenabled = false
@@ -0,0 +1,430 @@
+package ip2location
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The OverlayEntry struct stores the geolocation fields set for a CIDR block by an Overlay.
+type OverlayEntry struct {
+	Prefix netip.Prefix
+	Record IP2Locationrecord
+	Fields Field // fields of Record set by the entry
+
+	// Replace makes the entry replace the whole record from the BIN database
+	// instead of only the fields it sets.
+	Replace bool
+}
+
+// node of the binary prefix trie, one level per bit of the IP address
+type overlaynode struct {
+	child [2]*overlaynode
+	entry *OverlayEntry
+}
+
+// The Overlay struct wraps a DB with custom geolocation data for CIDR blocks, such as private or corporate ranges.
+// Entries take precedence over the BIN database, either for the whole record or field by field. When entries are
+// nested, the more specific entry wins for the fields they both set. IP addresses outside every entry are looked up
+// in the BIN database as they are, after a walk down a prefix trie that stops at the first bit no entry shares.
+type Overlay struct {
+	db *DB
+
+	mu   sync.RWMutex // guards the tries
+	ipv4 *overlaynode
+	ipv6 *overlaynode
+}
+
+// NewOverlay returns an empty Overlay on top of the DB.
+func NewOverlay(db *DB) *Overlay {
+	return &Overlay{db: db}
+}
+
+// Add adds or replaces the entry for a CIDR block. IPv4-mapped IPv6 blocks are stored as IPv4 blocks.
+// Lookups match IPv4 entries for the IPv6 addresses the MappingPolicy of the DB maps to IPv4, such as
+// 6to4, Teredo and NAT64 addresses, and IPv6 entries for the others.
+func (o *Overlay) Add(e OverlayEntry) error {
+	if !e.Prefix.IsValid() {
+		return ErrInvalidAddress
+	}
+	addr := e.Prefix.Addr()
+	bits := e.Prefix.Bits()
+	if addr.Is4In6() {
+		if bits < 96 {
+			return ErrInvalidAddress
+		}
+		addr = addr.Unmap()
+		bits -= 96
+	}
+	e.Prefix = netip.PrefixFrom(addr, bits).Masked()
+	e.Record.Fields = e.Fields
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	root := &o.ipv6
+	if addr.Is4() {
+		root = &o.ipv4
+	}
+	if *root == nil {
+		*root = &overlaynode{}
+	}
+	n := *root
+	b := addr.AsSlice()
+	for i := 0; i < bits; i++ {
+		bit := (b[i>>3] >> (7 - uint(i&7))) & 1
+		if n.child[bit] == nil {
+			n.child[bit] = &overlaynode{}
+		}
+		n = n.child[bit]
+	}
+	n.entry = &e
+	return nil
+}
+
+// returns the entries containing the IP address, from the least to the most specific, after mapping the
+// IPv6 transition addresses to IPv4 as the DB does
+func (o *Overlay) match(addr netip.Addr, found []*OverlayEntry) []*OverlayEntry {
+	iptype, ipnum, _ := o.db.mapipnum(addrtonum(addr))
+	if iptype == 0 {
+		return found
+	}
+	addr = numtoaddr(iptype, ipnum)
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	n := o.ipv6
+	if addr.Is4() {
+		n = o.ipv4
+	}
+	if n == nil {
+		return found
+	}
+	b := addr.AsSlice()
+	for i := 0; n != nil; i++ {
+		if n.entry != nil {
+			found = append(found, n.entry)
+		}
+		if i == len(b)*8 {
+			break
+		}
+		n = n.child[(b[i>>3]>>(7-uint(i&7)))&1]
+	}
+	return found
+}
+
+// Get_all will return all geolocation fields based on the queried IP address, with the overlay applied.
+func (o *Overlay) Get_all(ipaddress string) (IP2Locationrecord, error) {
+	return o.Query(ipaddress, FieldAll)
+}
+
+// Query will return the selected geolocation fields based on the queried IP address, with the overlay applied.
+func (o *Overlay) Query(ipaddress string, fields Field) (IP2Locationrecord, error) {
+	addr, err := netip.ParseAddr(ipaddress)
+	if err != nil || addr.Zone() != "" {
+		return IP2Locationrecord{}, ErrInvalidAddress
+	}
+	return o.QueryAddr(addr, fields)
+}
+
+// QueryAddr is like Query but takes a parsed IP address. Fields set by the overlay are returned
+// even if the BIN database does not have them or has no record for the IP address, but the error of
+// the BIN database is returned if no entry sets any of the fields asked for, as are other errors such
+// as read errors.
+func (o *Overlay) QueryAddr(addr netip.Addr, fields Field) (IP2Locationrecord, error) {
+	var buf [8]*OverlayEntry
+	found := o.match(addr, buf[:0])
+
+	// entries before the most specific one replacing the whole record do not matter, nor does the BIN
+	start := 0
+	for i, e := range found {
+		if e.Replace {
+			start = i
+		}
+	}
+
+	var x IP2Locationrecord
+	var err error
+	if len(found) == 0 || !found[start].Replace {
+		x, err = o.db.QueryAddr(addr, fields)
+		if len(found) == 0 {
+			return x, err
+		}
+		// the entries answer for the BIN database lacking the address or fields, not for a failed read, and
+		// only if they set some of the fields asked for
+		if err != nil {
+			missing := errors.Is(err, ErrNotFound) || errors.Is(err, ErrIPv6NotSupported) || errors.Is(err, ErrFieldNotSupported)
+			if !missing || !setsany(found[start:], fields) {
+				return x, err
+			}
+		}
+	}
+
+	for _, e := range found[start:] {
+		if e.Replace {
+			x = IP2Locationrecord{}
+		}
+		copyfields(&x, &e.Record, e.Fields&fields)
+	}
+	return x, nil
+}
+
+// returns whether any of the entries sets some of the fields
+func setsany(entries []*OverlayEntry, fields Field) bool {
+	for _, e := range entries {
+		if e.Fields&fields != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// copy the selected fields from another record
+func copyfields(x *IP2Locationrecord, e *IP2Locationrecord, fields Field) {
+	for _, c := range csvcolumns {
+		if fields&c.field == 0 {
+			continue
+		}
+		switch c.field {
+		case FieldLatitude:
+			x.Latitude = e.Latitude
+		case FieldLongitude:
+			x.Longitude = e.Longitude
+		case FieldElevation:
+			x.Elevation = e.Elevation
+		default:
+			setfield(x, c.field, c.value(e))
+		}
+	}
+	x.Fields |= fields
+}
+
+// set a field of the record from its CSV value
+func setfield(x *IP2Locationrecord, f Field, v string) error {
+	switch f {
+	case FieldCountryShort:
+		x.Country_short = v
+	case FieldCountryLong:
+		x.Country_long = v
+	case FieldRegion:
+		x.Region = v
+	case FieldCity:
+		x.City = v
+	case FieldISP:
+		x.Isp = v
+	case FieldLatitude, FieldLongitude, FieldElevation:
+		n, err := strconv.ParseFloat(v, 32)
+		if err != nil {
+			return err
+		}
+		switch f {
+		case FieldLatitude:
+			x.Latitude = float32(n)
+		case FieldLongitude:
+			x.Longitude = float32(n)
+		default:
+			x.Elevation = float32(n)
+		}
+	case FieldDomain:
+		x.Domain = v
+	case FieldZipCode:
+		x.Zipcode = v
+	case FieldTimeZone:
+		x.Timezone = v
+	case FieldNetSpeed:
+		x.Netspeed = v
+	case FieldIDDCode:
+		x.Iddcode = v
+	case FieldAreaCode:
+		x.Areacode = v
+	case FieldWeatherStationCode:
+		x.Weatherstationcode = v
+	case FieldWeatherStationName:
+		x.Weatherstationname = v
+	case FieldMCC:
+		x.Mcc = v
+	case FieldMNC:
+		x.Mnc = v
+	case FieldMobileBrand:
+		x.Mobilebrand = v
+	case FieldUsageType:
+		x.Usagetype = v
+	case FieldAddressType:
+		x.Addresstype = v
+	case FieldCategory:
+		x.Category = v
+	case FieldDistrict:
+		x.District = v
+	case FieldASN:
+		x.Asn = v
+	case FieldAS:
+		x.As = v
+	case FieldASDomain:
+		x.Asdomain = v
+	case FieldASUsageType:
+		x.Asusagetype = v
+	case FieldASCIDR:
+		x.Ascidr = v
+	}
+	return nil
+}
+
+// build an entry from the CIDR block, the precedence mode and the field values keyed by the IP2Location CSV column names
+func overlayentry(cidr string, mode string, values map[string]string) (OverlayEntry, error) {
+	var e OverlayEntry
+	var err error
+
+	if e.Prefix, err = netip.ParsePrefix(strings.TrimSpace(cidr)); err != nil {
+		return e, fmt.Errorf("invalid CIDR %q", cidr)
+	}
+
+	switch mode {
+	case "", "fields":
+	case "record":
+		e.Replace = true
+	default:
+		return e, fmt.Errorf("invalid mode %q, expected \"record\" or \"fields\"", mode)
+	}
+
+	for name, v := range values {
+		c, ok := csvcolumnbyname(name)
+		if !ok {
+			return e, fmt.Errorf("unknown column %q", name)
+		}
+		if v == "" {
+			if e.Replace {
+				e.Fields |= c.field // set empty
+			}
+			continue // left to the BIN database
+		}
+		if err = setfield(&e.Record, c.field, v); err != nil {
+			return e, fmt.Errorf("invalid %s %q", name, v)
+		}
+		e.Fields |= c.field
+	}
+	return e, nil
+}
+
+func csvcolumnbyname(name string) (csvcolumn, bool) {
+	for _, c := range csvcolumns {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return csvcolumn{}, false
+}
+
+// LoadCSV adds the entries of a CSV file. The first line names the columns: "cidr", an optional "mode"
+// that is "record" to replace the whole record or "fields" (the default) to replace only the fields set,
+// then any of the column names of ExportCSV such as "country_code", "city_name" or "latitude".
+// Empty values leave the field to the BIN database unless the mode is "record".
+//
+//	cidr,mode,country_code,country_name,city_name,latitude,longitude
+//	10.20.0.0/16,fields,SG,Singapore,Office-Singapore,1.2897,103.8501
+func (o *Overlay) LoadCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return err
+	}
+
+	cidrcol, modecol := -1, -1
+	for i, name := range header {
+		switch name {
+		case "cidr":
+			cidrcol = i
+		case "mode":
+			modecol = i
+		}
+	}
+	if cidrcol < 0 {
+		return errors.New("missing cidr column")
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var mode string
+		values := make(map[string]string, len(row))
+		for i, v := range row {
+			switch i {
+			case cidrcol:
+			case modecol:
+				mode = v
+			default:
+				values[header[i]] = v
+			}
+		}
+
+		e, err := overlayentry(row[cidrcol], mode, values)
+		if err == nil {
+			err = o.Add(e)
+		}
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// LoadJSON adds the entries of a JSON array of objects with the same keys as the columns of LoadCSV.
+// Values can be strings or numbers.
+//
+//	[{"cidr": "10.20.0.0/16", "country_code": "SG", "city_name": "Office-Singapore", "latitude": 1.2897}]
+func (o *Overlay) LoadJSON(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var items []map[string]interface{}
+	if err := dec.Decode(&items); err != nil {
+		return err
+	}
+
+	for i, item := range items {
+		var cidr, mode string
+		values := make(map[string]string, len(item))
+		for k, v := range item {
+			var s string
+			switch v := v.(type) {
+			case string:
+				s = v
+			case json.Number:
+				s = v.String()
+			case nil:
+			default:
+				return fmt.Errorf("entry %d: invalid value for %q", i, k)
+			}
+
+			switch k {
+			case "cidr":
+				cidr = s
+			case "mode":
+				mode = s
+			default:
+				values[k] = s
+			}
+		}
+
+		e, err := overlayentry(cidr, mode, values)
+		if err == nil {
+			err = o.Add(e)
+		}
+		if err != nil {
+			return fmt.Errorf("entry %d: %w", i, err)
+		}
+	}
+	return nil
+}
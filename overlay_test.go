@@ -0,0 +1,137 @@
+package ip2location_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+	"github.com/ip2location/ip2location-go/v9/ip2locationtest"
+)
+
+var corprec = ip2location.IP2Locationrecord{Country_short: "XX", City: "Head Office", Isp: "Corp"}
+
+func addcorp(t *testing.T, o *ip2location.Overlay, cidr string, fields ip2location.Field) {
+	t.Helper()
+
+	err := o.Add(ip2location.OverlayEntry{Prefix: netip.MustParsePrefix(cidr), Record: corprec, Fields: fields})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	o := ip2location.NewOverlay(opentestdb(t))
+	addcorp(t, o, "10.1.0.0/16", ip2location.FieldCity|ip2location.FieldISP)
+
+	x, err := o.Get_all("10.1.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.City != corprec.City || x.Isp != corprec.Isp || x.Country_short != privaterec.Country_short {
+		t.Errorf("Get_all(10.1.1.1) = %+v, want the city and ISP of the entry over the BIN record", x)
+	}
+
+	x, err = o.Get_all("10.2.1.1")
+	if err != nil || x.City != privaterec.City {
+		t.Errorf("Get_all(10.2.1.1) = %+v, %v, want the BIN record", x, err)
+	}
+}
+
+func TestOverlayAnswersForMissingData(t *testing.T) {
+	// DB1 without IPv6 data, so the BIN has neither the city nor the IPv6 addresses
+	db, err := ip2locationtest.OpenDB(1, ip2locationtest.Range{From: "10.0.0.0", To: "10.255.255.255", Record: privaterec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	o := ip2location.NewOverlay(db)
+	addcorp(t, o, "10.1.0.0/16", ip2location.FieldCity)
+	addcorp(t, o, "2001:db8::/32", ip2location.FieldCity)
+
+	for _, ip := range []string{"10.1.1.1", "2001:db8::1"} {
+		x, err := o.Query(ip, ip2location.FieldCity)
+		if err != nil || x.City != corprec.City {
+			t.Errorf("Query(%s) = %+v, %v, want the city of the entry", ip, x, err)
+		}
+	}
+}
+
+func TestOverlayReturnsReadErrors(t *testing.T) {
+	db, err := ip2location.OpenDB(writebin(t, testbin(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := ip2location.NewOverlay(db)
+	addcorp(t, o, "10.1.0.0/16", ip2location.FieldCity)
+	db.Close()
+
+	if x, err := o.Get_all("10.1.1.1"); err == nil {
+		t.Errorf("Get_all on a closed DB = %+v, want an error", x)
+	}
+}
+
+func TestOverlayMappingPolicy(t *testing.T) {
+	db := opentestdb(t)
+	o := ip2location.NewOverlay(db)
+	addcorp(t, o, "10.1.0.0/16", ip2location.FieldCity)
+
+	// 10.1.1.1 embedded in each kind of IPv6 transition address
+	mapped := map[string]string{
+		"ipv4-mapped": "::ffff:10.1.1.1",
+		"6to4":        "2002:a01:101::1",
+		"teredo":      "2001:0:4136:e378:8000:63bf:f5fe:fefe",
+		"nat64":       "64:ff9b::10.1.1.1",
+	}
+	check := func(policy string, want map[string]bool) {
+		t.Helper()
+		for name, ip := range mapped {
+			x, _ := o.Query(ip, ip2location.FieldCity)
+			if got := x.City == corprec.City; got != want[name] {
+				t.Errorf("%s: Query(%s) = %+v, want the entry for 10.1.0.0/16: %v", policy, ip, x, want[name])
+			}
+		}
+	}
+
+	check("default policy", map[string]bool{"ipv4-mapped": true, "6to4": true, "teredo": true})
+
+	if err := db.SetMappingPolicy(ip2location.MappingPolicy{NAT64: []netip.Prefix{ip2location.NAT64WellKnownPrefix}}); err != nil {
+		t.Fatal(err)
+	}
+	check("NAT64", map[string]bool{"ipv4-mapped": true, "6to4": true, "teredo": true, "nat64": true})
+
+	err := db.SetMappingPolicy(ip2location.MappingPolicy{DisableIPv4Mapped: true, Disable6to4: true, DisableTeredo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("no mapping", nil)
+
+	// with the mappings disabled the transition addresses are plain IPv6 addresses
+	addcorp(t, o, "2002::/16", ip2location.FieldCity)
+	if x, _ := o.Query(mapped["6to4"], ip2location.FieldCity); x.City != corprec.City {
+		t.Errorf("Query(%s) = %+v, want the entry for 2002::/16", mapped["6to4"], x)
+	}
+}
+
+func TestOverlayUnrelatedEntryKeepsErrors(t *testing.T) {
+	// DB1 without IPv6 data nor the ISP
+	db, err := ip2locationtest.OpenDB(1, ip2locationtest.Range{From: "10.0.0.0", To: "10.255.255.255", Record: privaterec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	o := ip2location.NewOverlay(db)
+	addcorp(t, o, "10.1.0.0/16", ip2location.FieldCity)
+	addcorp(t, o, "2001:db8::/32", ip2location.FieldCity)
+
+	if x, err := o.Query("2001:db8::1", ip2location.FieldCountryShort); err != ip2location.ErrIPv6NotSupported {
+		t.Errorf("Query(2001:db8::1, FieldCountryShort) = %+v, %v, want ErrIPv6NotSupported as the entry only sets the city", x, err)
+	}
+	if x, err := o.Query("10.1.1.1", ip2location.FieldISP); err != ip2location.ErrFieldNotSupported {
+		t.Errorf("Query(10.1.1.1, FieldISP) = %+v, %v, want ErrFieldNotSupported as the entry only sets the city", x, err)
+	}
+	if x, err := o.Query("2001:db8::1", ip2location.FieldCity|ip2location.FieldISP); err != nil || x.City != corprec.City {
+		t.Errorf("Query(2001:db8::1, FieldCity|FieldISP) = %+v, %v, want the city of the entry", x, err)
+	}
+}
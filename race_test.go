@@ -0,0 +1,6 @@
+//go:build race
+
+package ip2location_test
+
+// the race detector makes sync.Pool drop items at random, so allocations cannot be counted
+const raceenabled = true
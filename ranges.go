@@ -0,0 +1,203 @@
+package ip2location
+
+import (
+	"fmt"
+	"net/netip"
+
+	"lukechampine.com/uint128"
+)
+
+// The IPRange struct stores one IP range of the BIN database with its geolocation record.
+type IPRange struct {
+	IPFrom netip.Addr // first address of the range
+	IPTo   netip.Addr // last address of the range
+	Record IP2Locationrecord
+	IPv6   bool   // whether the range is from the IPv6 data
+	Row    uint32 // index of the row in the IPv4 or IPv6 data
+}
+
+// The RangeIterator struct walks the IP ranges of a BIN database in order, the IPv4 ranges
+// before the IPv6 ranges. The rows of both sections are numbered as one space, IPv4 first,
+// so that an iterator can be split into partitions for parallel scans.
+//
+//	it := db.Ranges(ip2location.FieldCountryShort)
+//	for it.Next() {
+//		r := it.Range()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// A RangeIterator must not be used from several goroutines at once, use Split instead.
+type RangeIterator struct {
+	d      *DB
+	fields Field
+	start  uint32 // first row of the iterator
+	end    uint32 // row after the last row of the iterator
+	pos    uint32 // next row to read
+	buf    []byte
+	cur    IPRange
+	err    error
+}
+
+// Ranges returns an iterator over all IPv4 and IPv6 ranges, decoding the selected fields of each range.
+func (d *DB) Ranges(fields Field) *RangeIterator {
+	return d.newiterator(fields, 0, d.meta.ipv4databasecount+d.meta.ipv6databasecount)
+}
+
+// RangesIPv4 returns an iterator over the IPv4 ranges only.
+func (d *DB) RangesIPv4(fields Field) *RangeIterator {
+	return d.newiterator(fields, 0, d.meta.ipv4databasecount)
+}
+
+// RangesIPv6 returns an iterator over the IPv6 ranges only.
+func (d *DB) RangesIPv6(fields Field) *RangeIterator {
+	return d.newiterator(fields, d.meta.ipv4databasecount, d.meta.ipv4databasecount+d.meta.ipv6databasecount)
+}
+
+func (d *DB) newiterator(fields Field, start uint32, end uint32) *RangeIterator {
+	it := &RangeIterator{d: d, fields: fields, start: start, end: end, pos: start}
+	if !d.metaok {
+		it.err = ErrInvalidDatabase
+	}
+	return it
+}
+
+// Next reads the next range, returning false once there are no more ranges or an error happened.
+func (it *RangeIterator) Next() bool {
+	d := it.d
+
+	for it.err == nil && it.pos < it.end {
+		pos := it.pos
+		it.pos++
+
+		iptype := uint32(4)
+		row := pos
+		baseaddr := d.meta.ipv4databaseaddr
+		colsize := d.meta.ipv4columnsize
+		var firstcol uint32 = 4 // 4 bytes for ip from
+		maxip := max_ipv4_range
+
+		if pos >= d.meta.ipv4databasecount {
+			iptype = 6
+			row = pos - d.meta.ipv4databasecount
+			baseaddr = d.meta.ipv6databaseaddr
+			colsize = d.meta.ipv6columnsize
+			firstcol = 16 // 16 bytes for ip from
+			maxip = max_ipv6_range
+		}
+
+		// reading IP From + whole row + next IP From
+		readlen := colsize + firstcol
+		if uint32(cap(it.buf)) < readlen {
+			it.buf = make([]byte, readlen)
+		}
+		fullrow, err := d.read_row_buf(it.buf, baseaddr+(row*colsize), readlen)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		var ipfrom, ipto uint128.Uint128
+		if iptype == 4 {
+			ipfrom = uint128.From64(uint64(d.readuint32_row(fullrow, 0)))
+			ipto = uint128.From64(uint64(d.readuint32_row(fullrow, colsize)))
+		} else {
+			ipfrom = d.readuint128_row(fullrow, 0)
+			ipto = d.readuint128_row(fullrow, colsize)
+		}
+
+		if ipfrom.Cmp(maxip) >= 0 {
+			continue // end marker of the section, not a range
+		}
+		if ipto.Cmp(ipfrom) <= 0 {
+			it.err = fmt.Errorf("%w: row %d ends before it starts", ErrInvalidDatabase, row)
+			return false
+		}
+
+		m := rowmatch{iptype: iptype, ipfrom: ipfrom, ipto: ipto, index: row, found: true}
+		from, to := m.bounds()
+
+		it.cur = IPRange{IPFrom: numtoaddr(iptype, from), IPTo: numtoaddr(iptype, to), IPv6: iptype == 6, Row: row}
+		if err = d.readrecord(fullrow[firstcol:colsize], it.fields, &it.cur.Record); err != nil {
+			it.err = err
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// Range returns the range read by the last call to Next.
+func (it *RangeIterator) Range() IPRange {
+	return it.cur
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *RangeIterator) Err() error {
+	return it.err
+}
+
+// Seek positions the iterator so that the next call to Next reads the range containing the IP address.
+// IPv4-mapped IPv6 addresses are sought in the IPv4 ranges, other IPv6 addresses in the IPv6 ranges.
+// If the range is outside the iterator, it is positioned at its start or its end, whichever is closer.
+func (it *RangeIterator) Seek(ipaddress string) error {
+	addr, err := netip.ParseAddr(ipaddress)
+	if err != nil || addr.Zone() != "" {
+		return ErrInvalidAddress
+	}
+	return it.SeekAddr(addr)
+}
+
+// SeekAddr is like Seek but takes a parsed IP address.
+func (it *RangeIterator) SeekAddr(addr netip.Addr) error {
+	d := it.d
+	if !d.metaok {
+		return ErrInvalidDatabase
+	}
+
+	iptype, ipno := addrtonum(addr.Unmap()) // no remapping of transition addresses, they have IPv6 ranges too
+
+	var x IP2Locationrecord
+	var m rowmatch
+	if err := d.queryinto(iptype, ipno, d.indexof(iptype, ipno), 0, &x, &m); err != nil {
+		return err
+	}
+
+	pos := m.index
+	if iptype == 6 {
+		pos += d.meta.ipv4databasecount
+	}
+	if pos < it.start {
+		pos = it.start
+	}
+	if pos > it.end {
+		pos = it.end
+	}
+	it.pos = pos
+	it.err = nil
+	return nil
+}
+
+// Split divides the rows the iterator has not read yet into n partitions of about the same size,
+// each with its own iterator that can be used from a separate goroutine.
+func (it *RangeIterator) Split(n int) []*RangeIterator {
+	if n < 1 {
+		n = 1
+	}
+
+	total := it.end - it.pos
+	parts := make([]*RangeIterator, 0, n)
+	start := it.pos
+	for i := 0; i < n; i++ {
+		end := it.pos + uint32(uint64(total)*uint64(i+1)/uint64(n))
+		part := it.d.newiterator(it.fields, start, end)
+		if it.err != nil {
+			part.err = it.err
+		}
+		parts = append(parts, part)
+		start = end
+	}
+	return parts
+}
@@ -0,0 +1,117 @@
+package ip2location_test
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// reads the ranges left in the iterator
+func readranges(t *testing.T, it *ip2location.RangeIterator) []ip2location.IPRange {
+	t.Helper()
+
+	var ranges []ip2location.IPRange
+	for it.Next() {
+		ranges = append(ranges, it.Range())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return ranges
+}
+
+// returns the position of the range containing the address
+func rangeindex(t *testing.T, ranges []ip2location.IPRange, ip string) int {
+	t.Helper()
+
+	addr := netip.MustParseAddr(ip).Unmap()
+	for i, r := range ranges {
+		if !addr.Less(r.IPFrom) && !r.IPTo.Less(addr) {
+			return i
+		}
+	}
+	t.Fatalf("no range contains %s", ip)
+	return -1
+}
+
+func TestRangeIteratorSeek(t *testing.T) {
+	db := opentestdb(t)
+	all := readranges(t, db.Ranges(ip2location.FieldCountryShort))
+	if n := len(readranges(t, db.RangesIPv4(ip2location.FieldCountryShort))); n == 0 || n == len(all) {
+		t.Fatalf("%d IPv4 ranges of %d, want both IPv4 and IPv6 ranges", n, len(all))
+	}
+
+	tests := []struct {
+		seek string
+		want int // index in all of the next range, len(all) when there is none
+	}{
+		{"0.0.0.0", 0},
+		{"10.128.0.1", rangeindex(t, all, "10.128.0.1")},           // the middle of a range
+		{"10.0.0.0", rangeindex(t, all, "10.0.0.0")},               // the first address of a range
+		{"::ffff:8.8.8.8", rangeindex(t, all, "8.8.8.8")},          // in the IPv4 ranges
+		{"2001:db8::1", rangeindex(t, all, "2001:db8::1")},         // in the IPv6 ranges
+		{"2002:808:808::1", rangeindex(t, all, "2002:808:808::1")}, // an IPv6 range, not the 6to4 mapping
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", len(all) - 1},
+	}
+	for _, tc := range tests {
+		it := db.Ranges(ip2location.FieldCountryShort)
+		it.Next() // Seek also moves an iterator that was already used
+		if err := it.Seek(tc.seek); err != nil {
+			t.Errorf("Seek(%s): %v", tc.seek, err)
+			continue
+		}
+		if got := readranges(t, it); !reflect.DeepEqual(got, all[tc.want:]) {
+			t.Errorf("ranges after Seek(%s) = %+v, want %+v", tc.seek, got, all[tc.want:])
+		}
+	}
+
+	// past the end of an IPv4 iterator, and before the start of an IPv6 one
+	it := db.RangesIPv4(ip2location.FieldCountryShort)
+	if err := it.Seek("2001:db8::1"); err != nil || it.Next() {
+		t.Errorf("RangesIPv4 after Seek(2001:db8::1) = %+v, %v, want no more ranges", it.Range(), err)
+	}
+	it = db.RangesIPv6(ip2location.FieldCountryShort)
+	if err := it.Seek("8.8.8.8"); err != nil || !it.Next() || !it.Range().IPv6 || it.Range().IPFrom.String() != "::" {
+		t.Errorf("RangesIPv6 after Seek(8.8.8.8) = %+v, %v, want the first IPv6 range", it.Range(), err)
+	}
+
+	if err := db.Ranges(0).Seek("bad"); err != ip2location.ErrInvalidAddress {
+		t.Errorf("Seek(bad) = %v, want ErrInvalidAddress", err)
+	}
+}
+
+func TestRangeIteratorSplit(t *testing.T) {
+	db := opentestdb(t)
+	all := readranges(t, db.Ranges(ip2location.FieldCountryShort))
+
+	// split at the row of a range, with rows already read before it
+	it := db.Ranges(ip2location.FieldCountryShort)
+	if err := it.Seek("10.0.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	first := rangeindex(t, all, "10.0.0.0")
+	for _, n := range []int{0, 1, 2, 3, len(all) - first, len(all)} {
+		var got []ip2location.IPRange
+		parts := it.Split(n)
+		if n > 0 && len(parts) != n {
+			t.Errorf("Split(%d) returned %d iterators", n, len(parts))
+		}
+		for i, part := range parts {
+			ranges := readranges(t, part)
+			if n == len(all)-first && len(ranges) != 1 {
+				t.Errorf("Split(%d) part %d has %d ranges, want one per row", n, i, len(ranges))
+			}
+			got = append(got, ranges...)
+		}
+		if !reflect.DeepEqual(got, all[first:]) {
+			t.Errorf("Split(%d) after Seek(10.0.0.0) gives %+v, want %+v", n, got, all[first:])
+		}
+	}
+
+	// the iterator split is left as it was
+	if got := readranges(t, it); !reflect.DeepEqual(got, all[first:]) {
+		t.Errorf("ranges after Split = %+v, want %+v", got, all[first:])
+	}
+}
@@ -0,0 +1,243 @@
+package ip2location
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"time"
+)
+
+// NetSpeed is the connection speed of a network, from the NETSPEED column.
+type NetSpeed uint8
+
+// Connection speeds of the IP2Location databases.
+const (
+	NetSpeedUnknown NetSpeed = iota
+	NetSpeedDial             // DIAL, dial-up
+	NetSpeedDSL              // DSL, broadband
+	NetSpeedCOMP             // COMP, company or T1
+	NetSpeedT1               // T1
+	NetSpeedSAT              // SAT, satellite
+)
+
+var netspeedcodes = [...]string{"-", "DIAL", "DSL", "COMP", "T1", "SAT"}
+
+// String returns the code of the connection speed as found in the databases.
+func (s NetSpeed) String() string {
+	if int(s) < len(netspeedcodes) {
+		return netspeedcodes[s]
+	}
+	return "-"
+}
+
+// AddressType is the routing type of an IP address, from the ADDRESS_TYPE column.
+type AddressType uint8
+
+// Address types of the IP2Location databases.
+const (
+	AddressTypeUnknown   AddressType = iota
+	AddressTypeAnycast               // A
+	AddressTypeUnicast               // U
+	AddressTypeMulticast             // M
+	AddressTypeBroadcast             // B
+)
+
+var addresstypecodes = [...]string{"-", "A", "U", "M", "B"}
+
+// String returns the code of the address type as found in the databases.
+func (t AddressType) String() string {
+	if int(t) < len(addresstypecodes) {
+		return addresstypecodes[t]
+	}
+	return "-"
+}
+
+// The Coordinates struct stores the location of a range.
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+
+	// Valid is false when the database has no coordinates, gives 0,0 for a range without a location,
+	// or gives a latitude or longitude out of range.
+	Valid bool
+}
+
+// The Record struct stores the geolocation info of the IP2Location database with its values parsed.
+// Values the database does not have, or has as "-", are left to their zero value and are not in Known.
+type Record struct {
+	CountryCode string
+	CountryName string
+	Region      string
+	City        string
+	District    string
+	ZipCode     string
+	Coordinates Coordinates
+	Elevation   float64       // meters above sea level
+	UTCOffset   time.Duration // offset of the time zone from UTC, without daylight saving time
+
+	ISP         string
+	Domain      string
+	NetSpeed    NetSpeed
+	UsageType   string
+	AddressType AddressType
+	Category    string
+
+	IDDCode            string
+	AreaCode           string
+	WeatherStationCode string
+	WeatherStationName string
+	MCC                string
+	MNC                string
+	MobileBrand        string
+
+	Asn         uint32
+	AS          string
+	ASDomain    string
+	ASUsageType string
+	ASCIDR      netip.Prefix
+
+	// Known lists the fields whose value is known. FieldLatitude and FieldLongitude are only known
+	// together, when Coordinates.Valid is set.
+	Known Field
+}
+
+// IsKnown returns whether the values of all the fields are known.
+func (r *Record) IsKnown(fields Field) bool {
+	return fields != 0 && r.Known&fields == fields
+}
+
+// Location returns the time zone as a fixed offset from UTC, and false if the time zone is not known.
+func (r *Record) Location() (*time.Location, bool) {
+	if r.Known&FieldTimeZone == 0 {
+		return time.UTC, false
+	}
+	return time.FixedZone("UTC"+formatoffset(r.UTCOffset), int(r.UTCOffset/time.Second)), true
+}
+
+// NewRecord converts a record of Query or Get_all to a Record.
+func NewRecord(x IP2Locationrecord) Record {
+	var r Record
+	str := func(f Field, s string, v *string) {
+		if x.Fields&f != 0 && s != "-" && s != "" {
+			*v = s
+			r.Known |= f
+		}
+	}
+
+	str(FieldCountryShort, x.Country_short, &r.CountryCode)
+	str(FieldCountryLong, x.Country_long, &r.CountryName)
+	str(FieldRegion, x.Region, &r.Region)
+	str(FieldCity, x.City, &r.City)
+	str(FieldDistrict, x.District, &r.District)
+	str(FieldZipCode, x.Zipcode, &r.ZipCode)
+	str(FieldISP, x.Isp, &r.ISP)
+	str(FieldDomain, x.Domain, &r.Domain)
+	str(FieldUsageType, x.Usagetype, &r.UsageType)
+	str(FieldCategory, x.Category, &r.Category)
+	str(FieldIDDCode, x.Iddcode, &r.IDDCode)
+	str(FieldAreaCode, x.Areacode, &r.AreaCode)
+	str(FieldWeatherStationCode, x.Weatherstationcode, &r.WeatherStationCode)
+	str(FieldWeatherStationName, x.Weatherstationname, &r.WeatherStationName)
+	str(FieldMCC, x.Mcc, &r.MCC)
+	str(FieldMNC, x.Mnc, &r.MNC)
+	str(FieldMobileBrand, x.Mobilebrand, &r.MobileBrand)
+	str(FieldAS, x.As, &r.AS)
+	str(FieldASDomain, x.Asdomain, &r.ASDomain)
+	str(FieldASUsageType, x.Asusagetype, &r.ASUsageType)
+
+	if x.Fields&(FieldLatitude|FieldLongitude) == FieldLatitude|FieldLongitude {
+		lat, lon := float32to64(x.Latitude), float32to64(x.Longitude)
+		if (lat != 0 || lon != 0) && lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180 {
+			r.Coordinates = Coordinates{Latitude: lat, Longitude: lon, Valid: true}
+			r.Known |= FieldLatitude | FieldLongitude
+		}
+	}
+
+	if x.Fields&FieldElevation != 0 {
+		r.Elevation = float32to64(x.Elevation)
+		r.Known |= FieldElevation
+	}
+
+	if x.Fields&FieldTimeZone != 0 {
+		if offset, ok := parseoffset(x.Timezone); ok {
+			r.UTCOffset = offset
+			r.Known |= FieldTimeZone
+		}
+	}
+
+	if x.Fields&FieldNetSpeed != 0 {
+		for i, code := range netspeedcodes {
+			if i > 0 && x.Netspeed == code {
+				r.NetSpeed = NetSpeed(i)
+				r.Known |= FieldNetSpeed
+			}
+		}
+	}
+
+	if x.Fields&FieldAddressType != 0 {
+		for i, code := range addresstypecodes {
+			if i > 0 && x.Addresstype == code {
+				r.AddressType = AddressType(i)
+				r.Known |= FieldAddressType
+			}
+		}
+	}
+
+	if x.Fields&FieldASN != 0 {
+		if n, err := strconv.ParseUint(x.Asn, 10, 32); err == nil {
+			r.Asn = uint32(n)
+			r.Known |= FieldASN
+		}
+	}
+
+	if x.Fields&FieldASCIDR != 0 {
+		if p, err := netip.ParsePrefix(x.Ascidr); err == nil {
+			r.ASCIDR = p
+			r.Known |= FieldASCIDR
+		}
+	}
+
+	return r
+}
+
+// QueryRecord will return the selected geolocation fields based on the queried IP address, parsed into a Record.
+func (d *DB) QueryRecord(ipaddress string, fields Field) (Record, error) {
+	x, err := d.query(ipaddress, fields)
+	if err != nil {
+		return Record{}, err
+	}
+	return NewRecord(x), nil
+}
+
+// convert a float32 of the BIN to the float64 it was written from, instead of its exact binary value
+func float32to64(v float32) float64 {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'f', -1, 32), 64)
+	return f
+}
+
+// parse a time zone of the form +08:00 or -05:30
+func parseoffset(s string) (time.Duration, bool) {
+	if len(s) != 6 || (s[0] != '+' && s[0] != '-') || s[3] != ':' {
+		return 0, false
+	}
+	h, err1 := strconv.ParseUint(s[1:3], 10, 8)
+	m, err2 := strconv.ParseUint(s[4:6], 10, 8)
+	if err1 != nil || err2 != nil || h > 14 || m > 59 {
+		return 0, false
+	}
+	offset := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+	if s[0] == '-' {
+		offset = -offset
+	}
+	return offset, true
+}
+
+// format an offset from UTC as +08:00 or -05:30
+func formatoffset(offset time.Duration) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	return fmt.Sprintf("%s%02d:%02d", sign, offset/time.Hour, offset%time.Hour/time.Minute)
+}
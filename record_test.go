@@ -0,0 +1,145 @@
+package ip2location_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// returns a DB26 with the test ranges and 9.9.9.0/24 in the United States with "-" as its elevation
+func noelevationdb(t *testing.T) *ip2location.DB {
+	t.Helper()
+
+	w, err := ip2location.NewBINWriter(26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range testranges {
+		if err := w.AddRecord(testranges[i].From, testranges[i].To, &testranges[i].Record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the CSV columns: the country code and name, then one per column of the schema
+	cols, _ := ip2location.Schema(26)
+	row := []string{"9.9.9.0", "9.9.9.255"}
+	for _, c := range cols {
+		switch c.Field {
+		case ip2location.FieldCountryShort | ip2location.FieldCountryLong:
+			row = append(row, "US", "United States of America")
+		case ip2location.FieldLatitude, ip2location.FieldLongitude:
+			row = append(row, "0")
+		default:
+			row = append(row, "-")
+		}
+	}
+	if err := w.Add(row); err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if _, err := w.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	db, err := ip2location.OpenDBWithBytes(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	return db
+}
+
+func TestRecordElevation(t *testing.T) {
+	tests := []struct {
+		ip        string
+		known     bool
+		elevation float64
+	}{
+		{"8.8.8.8", true, 32},
+		{"9.9.9.9", false, 0},   // "-" in the database
+		{"192.0.2.1", false, 0}, // "-" in the gap rows the BINWriter fills
+	}
+
+	for _, cache := range []int{0, 16} {
+		db := noelevationdb(t)
+		db.EnableCache(cache)
+
+		// with the cache, the first lookup of each address misses and the next ones hit
+		for _, lookup := range []string{"first", "second"} {
+			for _, tc := range tests {
+				x, err := db.Get_all(tc.ip)
+				if err != nil || (x.Fields&ip2location.FieldElevation != 0) != tc.known {
+					t.Errorf("cache %d, %s Get_all(%s) = %+v, %v, want FieldElevation listed: %v", cache, lookup, tc.ip, x, err, tc.known)
+				}
+
+				r, err := db.QueryRecord(tc.ip, ip2location.FieldElevation)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if r.IsKnown(ip2location.FieldElevation) != tc.known || r.Elevation != tc.elevation || r.Known&^ip2location.FieldElevation != 0 {
+					t.Errorf("cache %d, %s QueryRecord(%s) = %+v, want elevation %v known %v and no other field",
+						cache, lookup, tc.ip, r, tc.elevation, tc.known)
+				}
+			}
+		}
+		if s := db.CacheStats(); cache > 0 && (s.Misses != uint64(len(tests)) || s.Hits != 3*uint64(len(tests))) {
+			t.Errorf("cache stats %+v, want a miss for the first lookup of each address", s)
+		}
+	}
+
+	db := noelevationdb(t)
+	res, err := ip2location.NewMultiDB(db).Lookup("9.9.9.9", ip2location.FieldElevation)
+	if err != nil || res.Record.Fields != 0 || len(res.Sources) != 0 {
+		t.Errorf("MultiDB Lookup(9.9.9.9) = %+v, %v, want no elevation", res, err)
+	}
+}
+
+func TestExportCSVMissingElevation(t *testing.T) {
+	db := noelevationdb(t)
+
+	var b bytes.Buffer
+	if err := db.ExportCSV(&b, ip2location.CSVExportOptions{Fields: ip2location.FieldElevation, IPNotation: true, IPv4Only: true}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"8.8.8.0": "32", "9.9.9.0": "-", "0.0.0.0": "-"}
+	for _, row := range rows {
+		if v, ok := want[row[0]]; ok && row[2] != v {
+			t.Errorf("exported elevation of %s = %q, want %q", row[0], row[2], v)
+		}
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	x := googlerec
+	x.Fields = ip2location.FieldAll
+	r := ip2location.NewRecord(x)
+
+	if r.CountryCode != "US" || r.City != "Mountain View" || r.Asn != 15169 || r.ASCIDR != netip.MustParsePrefix("8.8.8.0/24") ||
+		r.NetSpeed != ip2location.NetSpeedT1 || r.AddressType != ip2location.AddressTypeAnycast || r.Elevation != 32 ||
+		r.Coordinates != (ip2location.Coordinates{Latitude: 37.40599, Longitude: -122.078514, Valid: true}) || r.UTCOffset != -7*time.Hour {
+		t.Errorf("NewRecord(%+v) = %+v", x, r)
+	}
+	// MCC, MNC and mobile brand are "-"
+	if want := ip2location.FieldAll &^ (ip2location.FieldMCC | ip2location.FieldMNC | ip2location.FieldMobileBrand); r.Known != want {
+		t.Errorf("Known = %v, want %v", r.Known, want)
+	}
+	if loc, ok := r.Location(); !ok || loc.String() != "UTC-07:00" {
+		t.Errorf("Location() = %v, %v", loc, ok)
+	}
+
+	// fields not listed in Fields are unknown, whatever their value
+	x.Fields = ip2location.FieldCity
+	if r := ip2location.NewRecord(x); r.Known != ip2location.FieldCity || r.Elevation != 0 || r.CountryCode != "" {
+		t.Errorf("NewRecord with Fields %v = %+v", x.Fields, r)
+	}
+	if _, ok := (&ip2location.Record{}).Location(); ok {
+		t.Error("Location() of an empty Record is known")
+	}
+}
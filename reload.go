@@ -0,0 +1,282 @@
+package ip2location
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const reloadable_closed string = "The reloadable database has been closed."
+
+// dbref is a reference-counted handle to a DB. The DB is closed once the last reference is released.
+type dbref struct {
+	db   *DB
+	refs int64
+}
+
+// acquire takes a reference unless the handle has already been released for good.
+func (r *dbref) acquire() bool {
+	for {
+		n := atomic.LoadInt64(&r.refs)
+		if n <= 0 {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&r.refs, n, n+1) {
+			return true
+		}
+	}
+}
+
+func (r *dbref) release() {
+	if atomic.AddInt64(&r.refs, -1) == 0 {
+		r.db.Close()
+	}
+}
+
+// The ReloadableDB struct wraps a DB that can be atomically replaced by a newer BIN file
+// while lookups are running. A replaced DB is closed only after all in-flight lookups on it are done.
+type ReloadableDB struct {
+	path   string
+	opener func(string) (*DB, error)
+	cur    atomic.Value // *dbref
+
+	mu       sync.Mutex // serialises reloads and guards the fields below
+	modtime  time.Time
+	size     int64
+	onReload func(db *DB)
+	onError  func(err error)
+	stop     chan struct{}
+	closed   bool
+}
+
+// OpenReloadableDB takes the path to the IP2Location BIN database file and opens it with OpenDB.
+// The same path is opened again on every reload.
+func OpenReloadableDB(dbpath string) (*ReloadableDB, error) {
+	return OpenReloadableDBWith(dbpath, OpenDB)
+}
+
+// OpenReloadableDBWith is like OpenReloadableDB but uses the supplied function to open the BIN file,
+// for example OpenDBInMemory or OpenDBMmap.
+func OpenReloadableDBWith(dbpath string, opener func(string) (*DB, error)) (*ReloadableDB, error) {
+	var r = &ReloadableDB{path: dbpath, opener: opener}
+
+	fi, err := os.Stat(dbpath)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := opener(dbpath)
+	if err != nil {
+		return nil, err
+	}
+
+	r.modtime = fi.ModTime()
+	r.size = fi.Size()
+	r.cur.Store(&dbref{db: db, refs: 1})
+	return r, nil
+}
+
+// OnReload sets the function called with the new DB after every successful reload.
+// It is called after the reload is done, so it may call the methods of the ReloadableDB.
+func (r *ReloadableDB) OnReload(fn func(db *DB)) {
+	r.mu.Lock()
+	r.onReload = fn
+	r.mu.Unlock()
+}
+
+// OnReloadError sets the function called with the error whenever a reload fails.
+// The previous DB stays in use when that happens. Like the OnReload function, it may call the methods of the ReloadableDB.
+func (r *ReloadableDB) OnReloadError(fn func(err error)) {
+	r.mu.Lock()
+	r.onError = fn
+	r.mu.Unlock()
+}
+
+// Reload opens the BIN file again and swaps it in. On failure the current DB is kept.
+func (r *ReloadableDB) Reload() error {
+	r.mu.Lock()
+	notify, err := r.reload()
+	r.mu.Unlock()
+
+	notify()
+	return err
+}
+
+// reload must be called with r.mu held. It returns the call of the callback for the outcome,
+// to be made once r.mu is released.
+func (r *ReloadableDB) reload() (func(), error) {
+	if r.closed {
+		return func() {}, errors.New(reloadable_closed)
+	}
+
+	fi, err := os.Stat(r.path)
+	if err == nil {
+		// remember the file even if it turns out to be broken so polling does not retry it until it changes again
+		r.modtime = fi.ModTime()
+		r.size = fi.Size()
+
+		var db *DB
+		db, err = r.opener(r.path)
+		if err == nil {
+			old := r.cur.Load().(*dbref)
+			ref := &dbref{db: db, refs: 2} // one reference for the callback, so that a later reload cannot close db under it
+			r.cur.Store(ref)
+			old.release()
+
+			onreload := r.onReload
+			return func() {
+				defer ref.release()
+				if onreload != nil {
+					onreload(db)
+				}
+			}, nil
+		}
+	}
+
+	onerror := r.onError
+	return func() {
+		if onerror != nil {
+			onerror(err)
+		}
+	}, err
+}
+
+// Watch polls the modification time and size of the BIN file at the given interval and reloads it
+// whenever either changes. Calling Watch again replaces the previous polling interval.
+func (r *ReloadableDB) Watch(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	if r.stop != nil {
+		close(r.stop)
+	}
+	stop := make(chan struct{})
+	r.stop = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				r.poll()
+			}
+		}
+	}()
+}
+
+// poll reloads the BIN file if it changed since it was last loaded.
+func (r *ReloadableDB) poll() {
+	fi, err := os.Stat(r.path)
+	if err != nil {
+		return // file is most likely in the middle of being replaced
+	}
+
+	r.mu.Lock()
+	if r.closed || (fi.ModTime().Equal(r.modtime) && fi.Size() == r.size) {
+		r.mu.Unlock()
+		return
+	}
+	notify, _ := r.reload()
+	r.mu.Unlock()
+
+	notify()
+}
+
+// acquire returns the current DB handle with a reference held.
+func (r *ReloadableDB) acquire() (*dbref, error) {
+	for {
+		ref, _ := r.cur.Load().(*dbref)
+		if ref == nil {
+			return nil, errors.New(reloadable_closed)
+		}
+		if ref.acquire() {
+			return ref, nil
+		}
+		// lost the race against a reload, the new handle is already stored
+		r.mu.Lock()
+		closed := r.closed
+		r.mu.Unlock()
+		if closed {
+			return nil, errors.New(reloadable_closed)
+		}
+	}
+}
+
+// Do calls fn with the current DB. The DB stays open until fn returns even if a reload happens meanwhile,
+// so fn must not keep the DB after returning.
+func (r *ReloadableDB) Do(fn func(db *DB) error) error {
+	ref, err := r.acquire()
+	if err != nil {
+		return err
+	}
+	defer ref.release()
+
+	return fn(ref.db)
+}
+
+// Get_all will return all geolocation fields based on the queried IP address.
+func (r *ReloadableDB) Get_all(ipaddress string) (IP2Locationrecord, error) {
+	var x IP2Locationrecord
+	err := r.Do(func(db *DB) error {
+		var err error
+		x, err = db.Get_all(ipaddress)
+		return err
+	})
+	return x, err
+}
+
+// Query will return the selected geolocation fields based on the queried IP address.
+func (r *ReloadableDB) Query(ipaddress string, fields Field) (IP2Locationrecord, error) {
+	var x IP2Locationrecord
+	err := r.Do(func(db *DB) error {
+		var err error
+		x, err = db.Query(ipaddress, fields)
+		return err
+	})
+	return x, err
+}
+
+// PackageVersion returns the database type of the current DB.
+func (r *ReloadableDB) PackageVersion() string {
+	var v string
+	_ = r.Do(func(db *DB) error {
+		v = db.PackageVersion()
+		return nil
+	})
+	return v
+}
+
+// DatabaseVersion returns the database version of the current DB.
+func (r *ReloadableDB) DatabaseVersion() string {
+	var v string
+	_ = r.Do(func(db *DB) error {
+		v = db.DatabaseVersion()
+		return nil
+	})
+	return v
+}
+
+// Close stops polling and closes the current DB once the lookups still running on it are done.
+func (r *ReloadableDB) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+	r.cur.Load().(*dbref).release()
+}
@@ -0,0 +1,79 @@
+package ip2location_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// runs fn and fails the test if it does not return within a few seconds
+func within(t *testing.T, what string, fn func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s did not return, the callbacks are likely called with the lock held", what)
+	}
+}
+
+func TestReloadCallbacksMayCallReloadableDB(t *testing.T) {
+	path := writebin(t, testbin(t))
+	r, err := ip2location.OpenReloadableDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// not closed on failure, as a deadlocked reload would hang Close too
+
+	var reloaded int
+	r.OnReload(func(db *ip2location.DB) {
+		reloaded++
+		if reloaded == 1 {
+			if err := r.Reload(); err != nil {
+				t.Errorf("Reload from OnReload: %v", err)
+			}
+		}
+		r.OnReloadError(nil)
+		if _, err := db.Get_all("8.8.8.8"); err != nil {
+			t.Errorf("Get_all on the reloaded DB: %v", err)
+		}
+	})
+	within(t, "Reload", func() {
+		if err := r.Reload(); err != nil {
+			t.Error(err)
+		}
+	})
+	if reloaded != 2 {
+		t.Errorf("OnReload called %d times, want 2", reloaded)
+	}
+
+	var failed error
+	r.OnReloadError(func(err error) {
+		failed = err
+		r.OnReload(nil)
+	})
+	if err := os.WriteFile(path, []byte("not a BIN"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	within(t, "failed Reload", func() {
+		if err := r.Reload(); err == nil {
+			t.Error("Reload of an invalid BIN succeeded")
+		}
+	})
+	if failed == nil {
+		t.Error("OnReloadError not called")
+	}
+
+	r.OnReloadError(func(error) { r.Close() })
+	within(t, "Reload closing the ReloadableDB", func() { _ = r.Reload() })
+	if _, err := r.Get_all("8.8.8.8"); err == nil {
+		t.Error("Get_all after Close succeeded")
+	}
+}
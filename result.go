@@ -0,0 +1,135 @@
+package ip2location
+
+import (
+	"encoding/binary"
+	"net/netip"
+
+	"lukechampine.com/uint128"
+)
+
+// The LookupResult struct stores the geolocation record together with the IP range it was found in
+// and the version of the BIN database that answered the lookup.
+type LookupResult struct {
+	Record IP2Locationrecord
+
+	// IPFrom and IPTo are the first and last address of the matched range. Addresses that are looked up
+	// in the IPv4 data, such as IPv4-mapped, 6to4 and Teredo addresses, report the IPv4 range.
+	IPFrom netip.Addr
+	IPTo   netip.Addr
+
+	// Mapping tells whether the address was looked up as the IPv4 address it embeds, and how.
+	Mapping Mapping
+
+	// Networks is the minimal set of CIDR blocks covering IPFrom to IPTo.
+	Networks []netip.Prefix
+
+	// Row is the index of the matched row in the IPv4 or IPv6 data.
+	Row uint32
+
+	DatabaseVersion string
+	PackageVersion  string
+}
+
+// Lookup will return the selected geolocation fields based on the queried IP address,
+// together with the IP range they apply to.
+func (d *DB) Lookup(ipaddress string, fields Field) (LookupResult, error) {
+	addr, err := netip.ParseAddr(ipaddress)
+	if err != nil || addr.Zone() != "" {
+		addr = netip.Addr{} // reported as an invalid address
+	}
+	return d.LookupAddrResult(addr, fields)
+}
+
+// LookupAddrResult is like Lookup but takes a parsed IP address.
+func (d *DB) LookupAddrResult(addr netip.Addr, fields Field) (LookupResult, error) {
+	var res LookupResult
+	var m rowmatch
+
+	iptype, ipno, mapping := d.mapipnum(addrtonum(addr))
+	res.Mapping = mapping
+
+	err := d.queryinto(iptype, ipno, d.indexof(iptype, ipno), fields, &res.Record, &m)
+	if err != nil {
+		return res, err
+	}
+
+	res.DatabaseVersion = d.DatabaseVersion()
+	res.PackageVersion = d.PackageVersion()
+
+	if m.found {
+		from, to := m.bounds()
+		res.IPFrom = numtoaddr(m.iptype, from)
+		res.IPTo = numtoaddr(m.iptype, to)
+		res.Networks = numtoprefixes(m.iptype, from, to)
+		res.Row = m.index
+	}
+	return res, nil
+}
+
+// bounds returns the first and last IP number of the matched range.
+func (m *rowmatch) bounds() (uint128.Uint128, uint128.Uint128) {
+	maxip := max_ipv4_range
+	if m.iptype == 6 {
+		maxip = max_ipv6_range
+	}
+
+	// ipto is exclusive except for the last range, which also holds the highest address
+	if m.ipto.Cmp(maxip) >= 0 {
+		return m.ipfrom, maxip
+	}
+	return m.ipfrom, m.ipto.Sub64(1)
+}
+
+// convert IP number to address
+func numtoaddr(iptype uint32, ipnum uint128.Uint128) netip.Addr {
+	if iptype == 4 {
+		var b [4]byte
+		binary.BigEndian.PutUint32(b[:], uint32(ipnum.Lo))
+		return netip.AddrFrom4(b)
+	}
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], ipnum.Hi)
+	binary.BigEndian.PutUint64(b[8:], ipnum.Lo)
+	return netip.AddrFrom16(b)
+}
+
+// returns the minimal list of CIDR blocks covering the IP number range
+func numtoprefixes(iptype uint32, from uint128.Uint128, to uint128.Uint128) []netip.Prefix {
+	bits := 32
+	if iptype == 6 {
+		bits = 128
+	}
+
+	var result []netip.Prefix
+	for from.Cmp(to) <= 0 {
+		// largest block aligned on from that does not go past to
+		size := from.TrailingZeros()
+		if size > bits {
+			size = bits
+		}
+		var last uint128.Uint128
+		for {
+			last = from.Or(hostmask(size))
+			if last.Cmp(to) <= 0 {
+				break
+			}
+			size--
+		}
+
+		result = append(result, netip.PrefixFrom(numtoaddr(iptype, from), bits-size))
+
+		if last.Cmp(to) >= 0 {
+			break
+		}
+		from = last.Add64(1)
+	}
+	return result
+}
+
+// returns a number with the lowest n bits set
+func hostmask(n int) uint128.Uint128 {
+	if n >= 128 {
+		return uint128.Max
+	}
+	return uint128.From64(1).Lsh(uint(n)).Sub64(1)
+}
@@ -0,0 +1,103 @@
+package ip2location_test
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+	"github.com/ip2location/ip2location-go/v9/ip2locationtest"
+)
+
+// parses the CIDR blocks
+func prefixes(s ...string) []netip.Prefix {
+	var p []netip.Prefix
+	for _, v := range s {
+		p = append(p, netip.MustParsePrefix(v))
+	}
+	return p
+}
+
+func TestLookupResultNetworks(t *testing.T) {
+	rec := ip2location.IP2Locationrecord{Country_short: "US", Country_long: "United States of America"}
+	db, err := ip2locationtest.OpenDB(1,
+		ip2locationtest.Range{From: "8.8.8.0", To: "8.8.8.255", Record: rec},
+		ip2locationtest.Range{From: "10.0.0.5", To: "10.0.1.2", Record: rec},
+		ip2locationtest.Range{From: "2001:db8::", To: "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", Record: rec},
+		ip2locationtest.Range{From: "2001:db9::1", To: "2001:db9::6", Record: rec},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	full, err := ip2locationtest.OpenDB(1,
+		ip2locationtest.Range{From: "0.0.0.0", To: "255.255.255.255", Record: rec},
+		ip2locationtest.Range{From: "::", To: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", Record: rec},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer full.Close()
+
+	tests := []struct {
+		db       *ip2location.DB
+		ip       string
+		from, to string
+		networks []netip.Prefix
+	}{
+		// aligned
+		{db, "8.8.8.8", "8.8.8.0", "8.8.8.255", prefixes("8.8.8.0/24")},
+		{db, "2001:db8::1", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", prefixes("2001:db8::/32")},
+		// unaligned at both ends
+		{db, "10.0.0.200", "10.0.0.5", "10.0.1.2", prefixes("10.0.0.5/32", "10.0.0.6/31", "10.0.0.8/29", "10.0.0.16/28",
+			"10.0.0.32/27", "10.0.0.64/26", "10.0.0.128/25", "10.0.1.0/31", "10.0.1.2/32")},
+		{db, "2001:db9::3", "2001:db9::1", "2001:db9::6", prefixes("2001:db9::1/128", "2001:db9::2/127", "2001:db9::4/127", "2001:db9::6/128")},
+		// the gaps filled by the writer, up to the last address
+		{db, "0.0.0.1", "0.0.0.0", "8.8.7.255", prefixes("0.0.0.0/5", "8.0.0.0/13", "8.8.0.0/21")},
+		{db, "192.0.2.1", "10.0.1.3", "255.255.255.255", prefixes("10.0.1.3/32", "10.0.1.4/30", "10.0.1.8/29", "10.0.1.16/28",
+			"10.0.1.32/27", "10.0.1.64/26", "10.0.1.128/25", "10.0.2.0/23", "10.0.4.0/22", "10.0.8.0/21", "10.0.16.0/20",
+			"10.0.32.0/19", "10.0.64.0/18", "10.0.128.0/17", "10.1.0.0/16", "10.2.0.0/15", "10.4.0.0/14", "10.8.0.0/13",
+			"10.16.0.0/12", "10.32.0.0/11", "10.64.0.0/10", "10.128.0.0/9", "11.0.0.0/8", "12.0.0.0/6", "16.0.0.0/4",
+			"32.0.0.0/3", "64.0.0.0/2", "128.0.0.0/1")},
+		{db, "2001:db9::7", "2001:db9::7", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", nil},
+		// the whole address space
+		{full, "1.2.3.4", "0.0.0.0", "255.255.255.255", prefixes("0.0.0.0/0")},
+		{full, "255.255.255.255", "0.0.0.0", "255.255.255.255", prefixes("0.0.0.0/0")},
+		{full, "2001:db8::1", "::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", prefixes("::/0")},
+	}
+	for _, tc := range tests {
+		res, err := tc.db.Lookup(tc.ip, ip2location.FieldCountryShort)
+		if err != nil {
+			t.Errorf("Lookup(%s): %v", tc.ip, err)
+			continue
+		}
+		if res.IPFrom != netip.MustParseAddr(tc.from) || res.IPTo != netip.MustParseAddr(tc.to) {
+			t.Errorf("Lookup(%s) range = %s - %s, want %s - %s", tc.ip, res.IPFrom, res.IPTo, tc.from, tc.to)
+		}
+		if tc.networks != nil && !reflect.DeepEqual(res.Networks, tc.networks) {
+			t.Errorf("Lookup(%s) networks = %v, want %v", tc.ip, res.Networks, tc.networks)
+		}
+		// the networks cover the range without gaps or overlaps
+		next := res.IPFrom
+		for i, p := range res.Networks {
+			if p.Addr() != next || p.Masked() != p {
+				t.Errorf("Lookup(%s) network %d is %s, want a block starting at %s", tc.ip, i, p, next)
+				break
+			}
+			next = lastaddr(p).Next()
+		}
+		if last := res.Networks[len(res.Networks)-1]; lastaddr(last) != res.IPTo {
+			t.Errorf("Lookup(%s) networks end at %s, want %s", tc.ip, lastaddr(last), res.IPTo)
+		}
+	}
+}
+
+// returns the last address of the block
+func lastaddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
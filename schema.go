@@ -0,0 +1,245 @@
+package ip2location
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+)
+
+const schema_registered string = "The database type already has a schema."
+const schema_no_columns string = "The schema has no columns."
+
+// the country column holds both the country code and, 3 bytes further, the country name
+const fieldcountry = FieldCountryShort | FieldCountryLong
+
+// The Column struct stores where a field is in the rows of a BIN database.
+type Column struct {
+	Field    Field // FieldCountryShort|FieldCountryLong for the country column, which holds both
+	Position int   // column number in the row, IP From being column 1
+}
+
+// fields of the columns after IP From for each database type of IP2Location
+var builtinschemas = [...][]Field{
+	1:  {fieldcountry},
+	2:  {fieldcountry, FieldISP},
+	3:  {fieldcountry, FieldRegion, FieldCity},
+	4:  {fieldcountry, FieldRegion, FieldCity, FieldISP},
+	5:  {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude},
+	6:  {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldISP},
+	7:  {fieldcountry, FieldRegion, FieldCity, FieldISP, FieldDomain},
+	8:  {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldISP, FieldDomain},
+	9:  {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode},
+	10: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldISP, FieldDomain},
+	11: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone},
+	12: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldISP, FieldDomain},
+	13: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldTimeZone, FieldNetSpeed},
+	14: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldISP, FieldDomain, FieldNetSpeed},
+	15: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldIDDCode, FieldAreaCode},
+	16: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldISP, FieldDomain, FieldNetSpeed, FieldIDDCode, FieldAreaCode},
+	17: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldTimeZone, FieldNetSpeed, FieldWeatherStationCode, FieldWeatherStationName},
+	18: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldISP, FieldDomain, FieldNetSpeed, FieldIDDCode, FieldAreaCode, FieldWeatherStationCode, FieldWeatherStationName},
+	19: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldISP, FieldDomain, FieldMCC, FieldMNC, FieldMobileBrand},
+	20: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldISP, FieldDomain, FieldNetSpeed, FieldIDDCode, FieldAreaCode, FieldWeatherStationCode, FieldWeatherStationName, FieldMCC, FieldMNC, FieldMobileBrand},
+	21: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldIDDCode, FieldAreaCode, FieldElevation},
+	22: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldISP, FieldDomain, FieldNetSpeed, FieldIDDCode, FieldAreaCode, FieldWeatherStationCode, FieldWeatherStationName, FieldMCC, FieldMNC, FieldMobileBrand, FieldElevation},
+	23: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldISP, FieldDomain, FieldMCC, FieldMNC, FieldMobileBrand, FieldUsageType},
+	24: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldISP, FieldDomain, FieldNetSpeed, FieldIDDCode, FieldAreaCode, FieldWeatherStationCode, FieldWeatherStationName, FieldMCC, FieldMNC, FieldMobileBrand, FieldElevation, FieldUsageType},
+	25: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldISP, FieldDomain, FieldNetSpeed, FieldIDDCode, FieldAreaCode, FieldWeatherStationCode, FieldWeatherStationName, FieldMCC, FieldMNC, FieldMobileBrand, FieldElevation, FieldUsageType, FieldAddressType, FieldCategory},
+	26: {fieldcountry, FieldRegion, FieldCity, FieldLatitude, FieldLongitude, FieldZipCode, FieldTimeZone, FieldISP, FieldDomain, FieldNetSpeed, FieldIDDCode, FieldAreaCode, FieldWeatherStationCode, FieldWeatherStationName, FieldMCC, FieldMNC, FieldMobileBrand, FieldElevation, FieldUsageType, FieldAddressType, FieldCategory, FieldDistrict, FieldASN, FieldAS, FieldASDomain, FieldASUsageType, FieldASCIDR},
+}
+
+// the largest database type of IP2Location, whose columns the unregistered types are assumed to start with
+const largest_builtin_type = 26
+
+// reads a field from the 32-bit value of its column, a string pointer or a float
+type columndecoder func(d *DB, v uint32, mode Field, x *IP2Locationrecord) error
+
+// a column of the opened BIN database with its decoder
+type columnreader struct {
+	field  Field
+	offset uint32 // byte offset in the row after IP From
+	decode columndecoder
+}
+
+func strcolumn(set func(x *IP2Locationrecord, s string)) columndecoder {
+	return func(d *DB, v uint32, mode Field, x *IP2Locationrecord) error {
+		s, err := d.readstr(v)
+		if err != nil {
+			return err
+		}
+		set(x, s)
+		return nil
+	}
+}
+
+// decoders of the fields the library can read
+var decoders = map[Field]columndecoder{
+	fieldcountry: func(d *DB, v uint32, mode Field, x *IP2Locationrecord) error {
+		var err error
+		if mode&FieldCountryShort != 0 {
+			if x.Country_short, err = d.readstr(v); err != nil {
+				return err
+			}
+		}
+		if mode&FieldCountryLong != 0 {
+			if x.Country_long, err = d.readstr(v + 3); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	FieldRegion: strcolumn(func(x *IP2Locationrecord, s string) { x.Region = s }),
+	FieldCity:   strcolumn(func(x *IP2Locationrecord, s string) { x.City = s }),
+	FieldISP:    strcolumn(func(x *IP2Locationrecord, s string) { x.Isp = s }),
+	FieldLatitude: func(d *DB, v uint32, mode Field, x *IP2Locationrecord) error {
+		x.Latitude = math.Float32frombits(v)
+		return nil
+	},
+	FieldLongitude: func(d *DB, v uint32, mode Field, x *IP2Locationrecord) error {
+		x.Longitude = math.Float32frombits(v)
+		return nil
+	},
+	FieldDomain:             strcolumn(func(x *IP2Locationrecord, s string) { x.Domain = s }),
+	FieldZipCode:            strcolumn(func(x *IP2Locationrecord, s string) { x.Zipcode = s }),
+	FieldTimeZone:           strcolumn(func(x *IP2Locationrecord, s string) { x.Timezone = s }),
+	FieldNetSpeed:           strcolumn(func(x *IP2Locationrecord, s string) { x.Netspeed = s }),
+	FieldIDDCode:            strcolumn(func(x *IP2Locationrecord, s string) { x.Iddcode = s }),
+	FieldAreaCode:           strcolumn(func(x *IP2Locationrecord, s string) { x.Areacode = s }),
+	FieldWeatherStationCode: strcolumn(func(x *IP2Locationrecord, s string) { x.Weatherstationcode = s }),
+	FieldWeatherStationName: strcolumn(func(x *IP2Locationrecord, s string) { x.Weatherstationname = s }),
+	FieldMCC:                strcolumn(func(x *IP2Locationrecord, s string) { x.Mcc = s }),
+	FieldMNC:                strcolumn(func(x *IP2Locationrecord, s string) { x.Mnc = s }),
+	FieldMobileBrand:        strcolumn(func(x *IP2Locationrecord, s string) { x.Mobilebrand = s }),
+	FieldElevation: func(d *DB, v uint32, mode Field, x *IP2Locationrecord) error {
+		s, err := d.readstr(v)
+		if err != nil {
+			return err
+		}
+		// stored as a string, "-" when the database has no elevation for the range
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			x.Fields &^= FieldElevation
+			return nil
+		}
+		x.Elevation = float32(f)
+		return nil
+	},
+	FieldUsageType:   strcolumn(func(x *IP2Locationrecord, s string) { x.Usagetype = s }),
+	FieldAddressType: strcolumn(func(x *IP2Locationrecord, s string) { x.Addresstype = s }),
+	FieldCategory:    strcolumn(func(x *IP2Locationrecord, s string) { x.Category = s }),
+	FieldDistrict:    strcolumn(func(x *IP2Locationrecord, s string) { x.District = s }),
+	FieldASN:         strcolumn(func(x *IP2Locationrecord, s string) { x.Asn = s }),
+	FieldAS:          strcolumn(func(x *IP2Locationrecord, s string) { x.As = s }),
+	FieldASDomain:    strcolumn(func(x *IP2Locationrecord, s string) { x.Asdomain = s }),
+	FieldASUsageType: strcolumn(func(x *IP2Locationrecord, s string) { x.Asusagetype = s }),
+	FieldASCIDR:      strcolumn(func(x *IP2Locationrecord, s string) { x.Ascidr = s }),
+}
+
+var schemamu sync.RWMutex // guards schemas
+var schemas = make(map[uint8][]Column)
+
+func init() {
+	for dbt, fields := range builtinschemas {
+		if len(fields) > 0 {
+			schemas[uint8(dbt)] = newschema(fields)
+		}
+	}
+}
+
+func newschema(fields []Field) []Column {
+	cols := make([]Column, len(fields))
+	for i, f := range fields {
+		cols[i] = Column{Field: f, Position: i + 2}
+	}
+	return cols
+}
+
+// RegisterSchema registers the columns of a database type that IP2Location does not define, so that
+// OpenDBWithReader can read BIN databases of that type and NewBINWriter can write them. The fields give the
+// columns after IP From in order, the country column being given as FieldCountryShort, FieldCountryLong or
+// both. It returns an error if the type already has a schema, no fields are given or a field is not a single
+// known field.
+//
+// A BIN database of a type without a schema still opens, but only with the columns that can be assumed: the
+// columns of DB26 if it has at least its 28 columns, otherwise only the country column, so register the schema
+// of such types to read their other columns.
+func RegisterSchema(databasetype uint8, fields ...Field) error {
+	if databasetype == 0 {
+		return errors.New(invalid_database_type)
+	}
+	if len(fields) == 0 {
+		return errors.New(schema_no_columns)
+	}
+
+	var seen Field
+	norm := make([]Field, len(fields))
+	for i, f := range fields {
+		if f&fieldcountry == f {
+			f = fieldcountry
+		}
+		if _, ok := decoders[f]; !ok {
+			return fmt.Errorf("column %d: %q is not a single known field", i+2, f.String())
+		}
+		if seen&f != 0 {
+			return fmt.Errorf("column %d: %s appears twice", i+2, f.String())
+		}
+		seen |= f
+		norm[i] = f
+	}
+
+	schemamu.Lock()
+	defer schemamu.Unlock()
+
+	if _, ok := schemas[databasetype]; ok {
+		return errors.New(schema_registered)
+	}
+	schemas[databasetype] = newschema(norm)
+	return nil
+}
+
+// Schema returns the columns of a database type after IP From, and false if the type has no schema.
+func Schema(databasetype uint8) ([]Column, bool) {
+	schemamu.RLock()
+	defer schemamu.RUnlock()
+
+	cols, ok := schemas[databasetype]
+	return append([]Column(nil), cols...), ok
+}
+
+// returns the columns to read for the database type. A type without a schema, such as one IP2Location
+// released after this library, is read with the columns it can be assumed to have: every type so far has the
+// country in column 2, and the larger types have added their columns after those of the largest one.
+func schemafor(dbt uint8, columns uint8) []Column {
+	if cols, ok := Schema(dbt); ok {
+		return cols
+	}
+
+	largest, _ := Schema(largest_builtin_type)
+	if int(columns) > len(largest) {
+		return largest
+	}
+	return largest[:1]
+}
+
+// returns the column of the field, 0 if the columns do not have it
+func columnposition(cols []Column, f Field) int {
+	for _, c := range cols {
+		if c.Field&f != 0 {
+			return c.Position
+		}
+	}
+	return 0
+}
+
+// sets up the readers of the columns and the fields they support
+func (d *DB) setcolumns(cols []Column) {
+	d.columns = cols
+	d.readers = make([]columnreader, 0, len(cols))
+	d.fields = 0
+	for _, c := range cols {
+		d.readers = append(d.readers, columnreader{field: c.Field, offset: uint32(c.Position-2) << 2, decode: decoders[c.Field]})
+		d.fields |= c.Field
+	}
+}
@@ -0,0 +1,70 @@
+package ip2location_test
+
+import (
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+	"github.com/ip2location/ip2location-go/v9/ip2locationtest"
+)
+
+// returns a BIN database of the built-in type with its type in the header changed
+func retypedbin(t *testing.T, builtin int, dbt uint8) []byte {
+	t.Helper()
+
+	data, err := ip2locationtest.Build(builtin, testranges...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[0] = dbt
+	return data
+}
+
+func TestUnknownDatabaseType(t *testing.T) {
+	for _, tc := range []struct {
+		builtin int
+		dbt     uint8
+		want    ip2location.Field
+	}{
+		{26, 200, ip2location.FieldAll},
+		{3, 201, ip2location.FieldCountryShort | ip2location.FieldCountryLong},
+	} {
+		db, err := ip2location.OpenDBWithBytes(retypedbin(t, tc.builtin, tc.dbt))
+		if err != nil {
+			t.Fatalf("DB%d as type %d: %v", tc.builtin, tc.dbt, err)
+		}
+		if got := db.SupportedFields(); got != tc.want {
+			t.Errorf("DB%d as type %d supports %v, want %v", tc.builtin, tc.dbt, got, tc.want)
+		}
+		if x, err := db.Get_all("8.8.8.8"); err != nil || x.Country_long != googlerec.Country_long {
+			t.Errorf("DB%d as type %d: Get_all(8.8.8.8) = %+v, %v", tc.builtin, tc.dbt, x, err)
+		}
+		db.Close()
+	}
+}
+
+func TestRegisterSchema(t *testing.T) {
+	const dbt = 202
+	fields := []ip2location.Field{ip2location.FieldCountryShort | ip2location.FieldCountryLong, ip2location.FieldRegion, ip2location.FieldCity}
+	if err := ip2location.RegisterSchema(dbt, fields...); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ip2location.UnregisterSchema(dbt) })
+	if err := ip2location.RegisterSchema(dbt, fields...); err == nil {
+		t.Error("RegisterSchema of a registered type succeeded")
+	}
+	if err := ip2location.RegisterSchema(dbt + 1); err == nil {
+		t.Error("RegisterSchema without columns succeeded")
+	}
+	if cols, ok := ip2location.Schema(dbt); !ok || len(cols) != 3 || cols[2].Field != ip2location.FieldCity || cols[2].Position != 4 {
+		t.Errorf("Schema(%d) = %+v, %v", dbt, cols, ok)
+	}
+
+	db, err := ip2location.OpenDBWithBytes(retypedbin(t, 3, dbt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if x, err := db.Get_all("8.8.8.8"); err != nil || x.City != googlerec.City || x.Region != googlerec.Region {
+		t.Errorf("Get_all(8.8.8.8) with the registered schema = %+v, %v", x, err)
+	}
+}
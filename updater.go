@@ -0,0 +1,337 @@
+package ip2location
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const default_download_url string = "https://www.ip2location.com/download/"
+
+// The Updater struct downloads an IP2Location database with a download token and replaces a local BIN file with it.
+// Each update checks the archive, validates the BIN and renames it over the local file, so a DB or ReloadableDB
+// opening the file never sees a partial download. The replaced files are kept as <Path>.1, <Path>.2 and so on.
+//
+//	u := &ip2location.Updater{Token: token, ProductCode: "DB11LITEBIN", Path: "./IP2LOCATION-LITE-DB11.BIN", Keep: 2}
+//	res, err := u.Update(ctx)
+type Updater struct {
+	Token       string // download token of the account
+	ProductCode string // database code, such as DB11LITEBIN
+	Path        string // local BIN file to update
+
+	// BaseURL is the download URL, to which the token and file query parameters are added.
+	// It defaults to the IP2Location download URL.
+	BaseURL string
+
+	// ChecksumURL returns the MD5 or SHA-256 hex digest of the archive, given the same token and file query
+	// parameters. If it is empty, the archive is only checked by the CRC-32 of its entries and by Validate.
+	ChecksumURL string
+
+	Client *http.Client // defaults to http.DefaultClient
+	Keep   int          // number of replaced files kept for Rollback
+}
+
+// The UpdateResult struct describes what an update did.
+type UpdateResult struct {
+	Updated         bool   // whether the local file was replaced
+	Version         string // database version of the local file after the update, empty if there is none
+	PreviousVersion string // database version of the local file before the update, empty if there was none
+}
+
+// returns the database version and date of a local BIN file, a zero date if it cannot be opened
+func localversion(dbpath string) (string, time.Time) {
+	db, err := OpenDB(dbpath)
+	if err != nil {
+		return "", time.Time{}
+	}
+	defer db.Close()
+
+	return db.DatabaseVersion(), db.Metadata().Date
+}
+
+// Update downloads the database unless the local file is from the current month, as IP2Location releases its
+// databases monthly. The downloaded file replaces the local file only if its database version is different.
+func (u *Updater) Update(ctx context.Context) (UpdateResult, error) {
+	var res UpdateResult
+	var date time.Time
+	res.PreviousVersion, date = localversion(u.Path)
+	res.Version = res.PreviousVersion
+
+	now := time.Now().UTC()
+	if !date.IsZero() && date.Year() == now.Year() && date.Month() == now.Month() {
+		return res, nil
+	}
+	return u.update(ctx, res)
+}
+
+// ForceUpdate is like Update but downloads the database whatever the date of the local file.
+func (u *Updater) ForceUpdate(ctx context.Context) (UpdateResult, error) {
+	var res UpdateResult
+	res.PreviousVersion, _ = localversion(u.Path)
+	res.Version = res.PreviousVersion
+	return u.update(ctx, res)
+}
+
+func (u *Updater) update(ctx context.Context, res UpdateResult) (UpdateResult, error) {
+	dir := filepath.Dir(u.Path)
+
+	archive, sums, err := u.download(ctx, dir)
+	if archive != "" {
+		defer os.Remove(archive)
+	}
+	if err != nil {
+		return res, err
+	}
+
+	if u.ChecksumURL != "" {
+		if err = u.checksum(ctx, sums); err != nil {
+			return res, err
+		}
+	}
+
+	// the BIN is written next to the local file so that the rename is atomic
+	bin, err := extractbin(archive, dir)
+	if bin != "" {
+		defer os.Remove(bin)
+	}
+	if err != nil {
+		return res, err
+	}
+
+	report, err := ValidateFile(bin)
+	if err != nil {
+		return res, err
+	}
+	if !report.OK() {
+		return res, fmt.Errorf("%w: %s", ErrInvalidDatabase, report.String())
+	}
+
+	version, _ := localversion(bin)
+	if version == res.PreviousVersion {
+		return res, nil // no newer release yet
+	}
+
+	if err = u.rotate(); err != nil {
+		return res, err
+	}
+	if err = os.Rename(bin, u.Path); err != nil {
+		return res, err
+	}
+	res.Updated = true
+	res.Version = version
+	return res, nil
+}
+
+// adds the token and file query parameters to the URL
+func (u *Updater) url(base string) (string, error) {
+	p, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := p.Query()
+	q.Set("token", u.Token)
+	q.Set("file", u.ProductCode)
+	p.RawQuery = q.Encode()
+	return p.String(), nil
+}
+
+func (u *Updater) get(ctx context.Context, base string) (*http.Response, error) {
+	if base == "" {
+		base = default_download_url
+	}
+	link, err := u.url(base)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("download of %s failed with HTTP status %s", u.ProductCode, res.Status)
+	}
+	return res, nil
+}
+
+// downloads the archive to a temporary file in dir, returning its path and its MD5 and SHA-256 digests
+func (u *Updater) download(ctx context.Context, dir string) (string, [2]hash.Hash, error) {
+	sums := [2]hash.Hash{md5.New(), sha256.New()}
+
+	res, err := u.get(ctx, u.BaseURL)
+	if err != nil {
+		return "", sums, err
+	}
+	defer res.Body.Close()
+
+	f, err := os.CreateTemp(dir, ".ip2location-*.zip")
+	if err != nil {
+		return "", sums, err
+	}
+	_, err = io.Copy(io.MultiWriter(f, sums[0], sums[1]), res.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return f.Name(), sums, err
+}
+
+// compares the digests of the archive with the one given by the checksum URL
+func (u *Updater) checksum(ctx context.Context, sums [2]hash.Hash) error {
+	res, err := u.get(ctx, u.ChecksumURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1024))
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(body))
+	if len(fields) == 0 {
+		return errors.New("empty checksum")
+	}
+	want := strings.ToLower(fields[0])
+
+	var got string
+	switch len(want) {
+	case md5.Size * 2:
+		got = hex.EncodeToString(sums[0].Sum(nil))
+	case sha256.Size * 2:
+		got = hex.EncodeToString(sums[1].Sum(nil))
+	default:
+		// the service answers errors such as an invalid token with a short text message
+		return fmt.Errorf("unrecognised checksum: %s", strings.TrimSpace(string(body)))
+	}
+	if got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, expected %s", u.ProductCode, got, want)
+	}
+	return nil
+}
+
+// extracts the first BIN file of the archive to a temporary file in dir
+func extractbin(archive string, dir string) (string, error) {
+	z, err := zip.OpenReader(archive)
+	if err != nil {
+		// the download service answers errors such as an invalid token with a short text message
+		if fi, serr := os.Stat(archive); serr == nil && fi.Size() < 1024 {
+			if msg, rerr := os.ReadFile(archive); rerr == nil {
+				return "", fmt.Errorf("download failed: %s", strings.TrimSpace(string(msg)))
+			}
+		}
+		return "", err
+	}
+	defer z.Close()
+
+	for _, f := range z.File {
+		if f.FileInfo().IsDir() || !strings.EqualFold(pathext(f.Name), ".bin") {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+
+		out, err := os.CreateTemp(dir, ".ip2location-*.BIN")
+		if err != nil {
+			return "", err
+		}
+		_, err = io.Copy(out, rc) // fails on a CRC-32 mismatch
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return out.Name(), fmt.Errorf("%s: %w", f.Name, err)
+		}
+		return out.Name(), nil
+	}
+	return "", errors.New(no_bin_in_zip)
+}
+
+// name of a kept file, 0 being the local file itself
+func (u *Updater) kept(i int) string {
+	if i == 0 {
+		return u.Path
+	}
+	return u.Path + "." + strconv.Itoa(i)
+}
+
+// shifts the kept files and keeps a link to the local file as <Path>.1, which stays in place until the rename
+func (u *Updater) rotate() error {
+	if u.Keep <= 0 {
+		return nil
+	}
+	if _, err := os.Stat(u.Path); err != nil {
+		return nil // nothing to keep
+	}
+
+	if err := os.Remove(u.kept(u.Keep)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := u.Keep - 1; i >= 1; i-- {
+		if err := os.Rename(u.kept(i), u.kept(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Link(u.Path, u.kept(1)); err != nil {
+		return copyfile(u.Path, u.kept(1)) // file systems without hard links
+	}
+	return nil
+}
+
+func copyfile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Rollback replaces the local file with the last kept file, <Path>.1, and shifts the other kept files down.
+func (u *Updater) Rollback() error {
+	if _, err := os.Stat(u.kept(1)); err != nil {
+		return err
+	}
+	for i := 1; ; i++ {
+		if err := os.Rename(u.kept(i), u.kept(i-1)); err != nil {
+			if os.IsNotExist(err) && i > 1 {
+				return nil
+			}
+			return err
+		}
+	}
+}
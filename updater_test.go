@@ -0,0 +1,302 @@
+package ip2location_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// returns the test BIN database with the date as its version
+func datedbin(t *testing.T, date time.Time) []byte {
+	t.Helper()
+
+	w, err := ip2location.NewBINWriter(26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetDate(date)
+	for i := range testranges {
+		if err := w.AddRecord(testranges[i].From, testranges[i].To, &testranges[i].Record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b bytes.Buffer
+	if _, err := w.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// returns a ZIP archive holding the BIN database as IP2Location packs it
+func zipbin(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	z := zip.NewWriter(&b)
+	for name, content := range map[string][]byte{"README_LITE.TXT": []byte("readme"), "IP2LOCATION-LITE-DB26.BIN": data} {
+		f, err := z.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// stands in for the download service, answering /download and /checksum
+type downloadserver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	archive  []byte
+	checksum string
+	requests []string
+}
+
+func newdownloadserver(t *testing.T) *downloadserver {
+	s := &downloadserver{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		s.requests = append(s.requests, req.URL.Path)
+		if q := req.URL.Query(); q.Get("token") != "secret" || q.Get("file") != "DB26LITEBIN" {
+			_, _ = w.Write([]byte("INVALID TOKEN OR FILE"))
+			return
+		}
+		switch req.URL.Path {
+		case "/download":
+			_, _ = w.Write(s.archive)
+		case "/checksum":
+			_, _ = w.Write([]byte(s.checksum))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// sets the archive served and returns its MD5 and SHA-256 digests
+func (s *downloadserver) serve(archive []byte) (string, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.archive = archive
+	m := md5.Sum(archive)
+	h := sha256.Sum256(archive)
+	return hex.EncodeToString(m[:]), hex.EncodeToString(h[:])
+}
+
+func (s *downloadserver) setchecksum(sum string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checksum = sum
+}
+
+func (s *downloadserver) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.requests)
+}
+
+func (s *downloadserver) updater(path string) *ip2location.Updater {
+	return &ip2location.Updater{Token: "secret", ProductCode: "DB26LITEBIN", Path: path, BaseURL: s.URL + "/download"}
+}
+
+func version(t *testing.T, path string) string {
+	t.Helper()
+
+	db, err := ip2location.OpenDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	return db.DatabaseVersion()
+}
+
+var (
+	january  = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	february = time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)
+	march    = time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func TestUpdaterUpdateAndRollback(t *testing.T) {
+	s := newdownloadserver(t)
+	path := t.TempDir() + "/IP2LOCATION-LITE-DB26.BIN"
+	u := s.updater(path)
+	u.Keep = 2
+	ctx := context.Background()
+
+	s.serve(zipbin(t, datedbin(t, january)))
+	res, err := u.Update(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := version(t, path)
+	if !res.Updated || res.Version != v1 || res.PreviousVersion != "" {
+		t.Errorf("first update = %+v, want version %s installed", res, v1)
+	}
+
+	// same release: downloaded but not replaced
+	res, err = u.ForceUpdate(ctx)
+	if err != nil || res.Updated || res.Version != v1 || res.PreviousVersion != v1 {
+		t.Errorf("update to the same version = %+v, %v, want no change", res, err)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("update to the same version kept a copy: %v", err)
+	}
+
+	s.serve(zipbin(t, datedbin(t, february)))
+	if res, err = u.Update(ctx); err != nil || !res.Updated || res.PreviousVersion != v1 {
+		t.Fatalf("update to February = %+v, %v", res, err)
+	}
+	v2 := version(t, path)
+	s.serve(zipbin(t, datedbin(t, march)))
+	if res, err = u.Update(ctx); err != nil || !res.Updated || res.PreviousVersion != v2 {
+		t.Fatalf("update to March = %+v, %v", res, err)
+	}
+	v3 := version(t, path)
+	if v1 == v2 || v2 == v3 {
+		t.Fatalf("versions %s, %s and %s are not distinct", v1, v2, v3)
+	}
+	if got1, got2 := version(t, path+".1"), version(t, path+".2"); got1 != v2 || got2 != v1 {
+		t.Errorf("kept versions %s and %s, want %s and %s", got1, got2, v2, v1)
+	}
+
+	// one more update drops the oldest kept file
+	s.serve(zipbin(t, datedbin(t, march.AddDate(0, 0, 1))))
+	if _, err = u.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got1, got2 := version(t, path+".1"), version(t, path+".2"); got1 != v3 || got2 != v2 {
+		t.Errorf("kept versions %s and %s, want %s and %s", got1, got2, v3, v2)
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("more than Keep files kept: %v", err)
+	}
+
+	if err := u.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got, got1 := version(t, path), version(t, path+".1"); got != v3 || got1 != v2 {
+		t.Errorf("after Rollback the versions are %s and %s, want %s and %s", got, got1, v3, v2)
+	}
+	if err := u.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if got := version(t, path); got != v2 {
+		t.Errorf("after two Rollbacks the version is %s, want %s", got, v2)
+	}
+	if err := u.Rollback(); err == nil {
+		t.Error("Rollback without kept files succeeded")
+	}
+}
+
+func TestUpdaterSkipsCurrentMonth(t *testing.T) {
+	s := newdownloadserver(t)
+	path := writebin(t, datedbin(t, time.Now().UTC()))
+	s.serve(zipbin(t, datedbin(t, january)))
+
+	res, err := s.updater(path).Update(context.Background())
+	if err != nil || res.Updated || res.Version == "" {
+		t.Errorf("Update of a file from this month = %+v, %v, want no change", res, err)
+	}
+	if n := s.count(); n != 0 {
+		t.Errorf("Update of a file from this month made %d requests", n)
+	}
+}
+
+func TestUpdaterChecksum(t *testing.T) {
+	s := newdownloadserver(t)
+	path := writebin(t, datedbin(t, january))
+	u := s.updater(path)
+	u.ChecksumURL = s.URL + "/checksum"
+	ctx := context.Background()
+
+	md5sum, sha256sum := s.serve(zipbin(t, datedbin(t, february)))
+	for _, bad := range []string{strings.Repeat("0", 32), strings.Repeat("0", 64)} {
+		s.setchecksum(bad)
+		if _, err := u.Update(ctx); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+			t.Errorf("Update with a wrong checksum = %v, want a checksum mismatch", err)
+		}
+	}
+	s.setchecksum("garbage")
+	if _, err := u.Update(ctx); err == nil || !strings.Contains(err.Error(), "unrecognised checksum") {
+		t.Errorf("Update with an unrecognised checksum = %v", err)
+	}
+	if got := version(t, path); got != version(t, writebin(t, datedbin(t, january))) {
+		t.Errorf("failed updates replaced the local file with version %s", got)
+	}
+
+	for _, sum := range []string{md5sum, strings.ToUpper(sha256sum) + "  IP2LOCATION-LITE-DB26.BIN.ZIP\n"} {
+		s.setchecksum(sum)
+		if _, err := u.ForceUpdate(ctx); err != nil {
+			t.Errorf("Update with checksum %q: %v", sum, err)
+		}
+	}
+	if got := version(t, path); got != version(t, writebin(t, datedbin(t, february))) {
+		t.Errorf("Update with the right checksum left version %s", got)
+	}
+}
+
+func TestUpdaterTokenError(t *testing.T) {
+	s := newdownloadserver(t)
+	path := writebin(t, datedbin(t, january))
+	u := s.updater(path)
+	u.Token = "wrong"
+	s.serve(zipbin(t, datedbin(t, february)))
+
+	_, err := u.Update(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "download failed: INVALID TOKEN OR FILE") {
+		t.Errorf("Update with a wrong token = %v, want the message of the service", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("the failed update left %d files next to the local file", len(entries)-1)
+	}
+}
+
+func TestUpdaterValidateFailure(t *testing.T) {
+	s := newdownloadserver(t)
+	path := writebin(t, datedbin(t, january))
+	u := s.updater(path)
+	u.Keep = 1
+
+	bad := datedbin(t, february)
+	binary.LittleEndian.PutUint32(bad[31:], uint32(len(bad)+1)) // header file size
+	s.serve(zipbin(t, bad))
+
+	res, err := u.Update(context.Background())
+	if !errors.Is(err, ip2location.ErrInvalidDatabase) || res.Updated {
+		t.Errorf("Update to a BIN failing Validate = %+v, %v, want ErrInvalidDatabase", res, err)
+	}
+	if got := version(t, path); got != version(t, writebin(t, datedbin(t, january))) {
+		t.Errorf("the invalid BIN replaced the local file, now version %s", got)
+	}
+	if _, err := os.Stat(path + ".1"); !os.IsNotExist(err) {
+		t.Errorf("the failed update rotated the local file: %v", err)
+	}
+}
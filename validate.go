@@ -0,0 +1,299 @@
+package ip2location
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"lukechampine.com/uint128"
+)
+
+// stop listing problems after this many, so that a badly damaged file gives a readable report
+const max_validation_problems = 100
+
+// rows of a section checked at most when the reader cannot tell the file size, so that a corrupted row count
+// cannot make Validate read and hold billions of rows; the largest IP2Location databases have a few million
+const max_unsized_rows = 1 << 26
+
+// The ValidationReport struct stores the outcome of a deep check of a BIN database.
+type ValidationReport struct {
+	FileSize     int64  // actual size of the BIN file, -1 if the reader cannot tell
+	IPv4Rows     uint32 // IPv4 rows checked
+	IPv6Rows     uint32 // IPv6 rows checked
+	IndexEntries int    // index entries checked
+	Strings      int    // distinct strings checked
+
+	// Problems describes everything found wrong, up to 100 entries. TotalProblems counts them all.
+	Problems      []string
+	TotalProblems int
+}
+
+// OK returns true if no problem was found.
+func (r ValidationReport) OK() bool {
+	return r.TotalProblems == 0
+}
+
+// String returns a summary of the report followed by the problems found, one per line.
+func (r ValidationReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "file size %d, %d IPv4 rows, %d IPv6 rows, %d index entries, %d strings checked: ", r.FileSize, r.IPv4Rows, r.IPv6Rows, r.IndexEntries, r.Strings)
+	if r.OK() {
+		b.WriteString("OK")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d problems", r.TotalProblems)
+	for _, p := range r.Problems {
+		b.WriteString("\n")
+		b.WriteString(p)
+	}
+	if r.TotalProblems > len(r.Problems) {
+		fmt.Fprintf(&b, "\n... and %d more", r.TotalProblems-len(r.Problems))
+	}
+	return b.String()
+}
+
+func (r *ValidationReport) problem(format string, args ...interface{}) {
+	r.TotalProblems++
+	if len(r.Problems) < max_validation_problems {
+		r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// ValidateFile opens the BIN database at the given path and checks it with Validate.
+// The error is only set if the file cannot be opened at all.
+func ValidateFile(dbpath string) (ValidationReport, error) {
+	db, err := OpenDB(dbpath)
+	if err != nil {
+		return ValidationReport{}, err
+	}
+	defer db.Close()
+
+	return db.Validate(), nil
+}
+
+// returns the actual size of the BIN, -1 if the reader cannot tell
+func (d *DB) actualsize() int64 {
+	switch f := d.f.(type) {
+	case *os.File:
+		if fi, err := f.Stat(); err == nil {
+			return fi.Size()
+		}
+	case interface{ Size() int64 }:
+		return f.Size()
+	}
+	return -1
+}
+
+// Validate reads the whole BIN database and checks that the file size in the header matches the file,
+// that the IPv4 and IPv6 rows are sorted and cover their whole IP range, that every index entry gives a
+// row window containing its IP range and that every string pointer and string stays inside the file.
+// It is meant to reject a truncated or corrupted download before it is used and takes a while on large files.
+func (d *DB) Validate() ValidationReport {
+	r := ValidationReport{FileSize: d.actualsize()}
+	if !d.metaok {
+		r.problem("header: %s", invalid_bin)
+		return r
+	}
+
+	if r.FileSize >= 0 && d.meta.filesize != 0 && int64(d.meta.filesize) != r.FileSize {
+		r.problem("header: file size is %d but the file has %d bytes", d.meta.filesize, r.FileSize)
+	}
+
+	strs := make(map[uint32]struct{})
+	if ipfrom, ok := d.validaterows(&r, 4, strs); ok {
+		r.IPv4Rows = d.meta.ipv4databasecount
+		if d.meta.ipv4indexed {
+			d.validateindex(&r, 4, ipfrom)
+		}
+	}
+	if d.meta.ipv6databasecount > 0 {
+		if ipfrom, ok := d.validaterows(&r, 6, strs); ok {
+			r.IPv6Rows = d.meta.ipv6databasecount
+			if d.meta.ipv6indexed {
+				d.validateindex(&r, 6, ipfrom)
+			}
+		}
+	}
+	d.validatestrings(&r, strs)
+	return r
+}
+
+// checks that a section fits in the file
+func (d *DB) validatesection(r *ValidationReport, name string, addr uint32, size int64) bool {
+	if addr == 0 {
+		r.problem("%s: address is 0", name)
+		return false
+	}
+	if r.FileSize >= 0 && int64(addr)-1+size > r.FileSize {
+		r.problem("%s: %d bytes at %d go past the end of the file", name, size, addr-1)
+		return false
+	}
+	if int64(addr)-1+size > 1<<32 {
+		r.problem("%s: %d bytes at %d go past the 4 GiB a BIN database can address", name, size, addr-1)
+		return false
+	}
+	return true
+}
+
+// reads the IP From of every row of a section, including the end marker, checking their order and
+// collecting the string pointers of the rows
+func (d *DB) validaterows(r *ValidationReport, iptype uint32, strs map[uint32]struct{}) ([]uint128.Uint128, bool) {
+	name := "IPv4 rows"
+	count := d.meta.ipv4databasecount
+	baseaddr := d.meta.ipv4databaseaddr
+	colsize := d.meta.ipv4columnsize
+	firstcol := uint32(4)
+	maxip := max_ipv4_range
+	if iptype == 6 {
+		name = "IPv6 rows"
+		count = d.meta.ipv6databasecount
+		baseaddr = d.meta.ipv6databaseaddr
+		colsize = d.meta.ipv6columnsize
+		firstcol = 16
+		maxip = max_ipv6_range
+	}
+
+	rows := int64(count) + 1 // with the end marker
+	if !d.validatesection(r, name, baseaddr, rows*int64(colsize)) {
+		return nil, false
+	}
+	if r.FileSize < 0 && rows > max_unsized_rows {
+		r.problem("%s: %d rows are too many to check without knowing the file size", name, count)
+		return nil, false
+	}
+
+	// columns holding string pointers, the country column also points to the country name 3 bytes further
+	var strcols []uint32
+	countrycol := int64(-1)
+	for _, c := range d.readers {
+		switch c.field {
+		case FieldLatitude, FieldLongitude:
+			continue
+		case fieldcountry:
+			countrycol = int64(len(strcols))
+		}
+		strcols = append(strcols, c.offset)
+	}
+
+	const chunk = 4096 // rows read at once
+	ipfrom := make([]uint128.Uint128, 0, rows)
+	buf := make([]byte, chunk*colsize)
+	for start := uint32(0); int64(start) < rows; start += chunk {
+		n := uint32(rows - int64(start))
+		if n > chunk {
+			n = chunk
+		}
+		data, err := d.read_row_buf(buf, baseaddr+start*colsize, n*colsize)
+		if err != nil {
+			r.problem("%s: cannot read rows %d to %d: %v", name, start, start+n-1, err)
+			return nil, false
+		}
+
+		for i := uint32(0); i < n; i++ {
+			row := data[i*colsize : (i+1)*colsize]
+			var from uint128.Uint128
+			if iptype == 4 {
+				from = uint128.From64(uint64(d.readuint32_row(row, 0)))
+			} else {
+				from = d.readuint128_row(row, 0)
+			}
+			ipfrom = append(ipfrom, from)
+
+			if start+i == count {
+				break // the end marker only gives the IP To of the last row
+			}
+			for j, off := range strcols {
+				if firstcol+off+4 > colsize {
+					continue
+				}
+				p := d.readuint32_row(row, firstcol+off)
+				strs[p] = struct{}{}
+				if int64(j) == countrycol {
+					strs[p+3] = struct{}{}
+				}
+			}
+		}
+	}
+
+	if !ipfrom[0].IsZero() {
+		r.problem("%s: first row starts at %s instead of %s", name, numtoaddr(iptype, ipfrom[0]), numtoaddr(iptype, uint128.Zero))
+	}
+	for i := 1; i < len(ipfrom); i++ {
+		if ipfrom[i].Cmp(ipfrom[i-1]) <= 0 {
+			r.problem("%s: row %d starts at %s, not after row %d at %s", name, i, numtoaddr(iptype, ipfrom[i]), i-1, numtoaddr(iptype, ipfrom[i-1]))
+		}
+	}
+	if last := ipfrom[count]; last.Cmp(maxip) < 0 {
+		r.problem("%s: rows end at %s instead of covering up to %s", name, numtoaddr(iptype, last), numtoaddr(iptype, maxip))
+	}
+	return ipfrom, true
+}
+
+// checks that every index entry gives rows containing the first and last IP number of its block
+func (d *DB) validateindex(r *ValidationReport, iptype uint32, ipfrom []uint128.Uint128) {
+	name := "IPv4 index"
+	indexaddr := d.meta.ipv4indexbaseaddr
+	shift := uint(16)
+	if iptype == 6 {
+		name = "IPv6 index"
+		indexaddr = d.meta.ipv6indexbaseaddr
+		shift = 112
+	}
+
+	if !d.validatesection(r, name, indexaddr, 65536*8) {
+		return
+	}
+	index, err := d.read_row_buf(make([]byte, 65536*8), indexaddr, 65536*8)
+	if err != nil {
+		r.problem("%s: cannot read: %v", name, err)
+		return
+	}
+
+	count := uint32(len(ipfrom) - 1)
+	blocksize := uint128.Max.Rsh(128 - shift)
+	for b := uint32(0); b < 65536; b++ {
+		low := d.readuint32_row(index, b*8)
+		high := d.readuint32_row(index, b*8+4)
+		r.IndexEntries++
+
+		first := uint128.From64(uint64(b)).Lsh(shift)
+		last := first.Add(blocksize)
+		if b == 65535 {
+			last = last.Sub64(1) // lookups of the highest address search for the one before
+		}
+
+		switch {
+		case low > high || high >= count:
+			r.problem("%s: entry %d gives rows %d to %d outside the %d rows", name, b, low, high, count)
+		case ipfrom[low].Cmp(first) > 0 || ipfrom[high+1].Cmp(last) <= 0:
+			r.problem("%s: entry %d gives rows %d to %d, which do not contain %s to %s", name, b, low, high,
+				numtoaddr(iptype, first), numtoaddr(iptype, last))
+		}
+	}
+}
+
+// checks that every string and its length byte are inside the file
+func (d *DB) validatestrings(r *ValidationReport, strs map[uint32]struct{}) {
+	ptrs := make([]uint32, 0, len(strs))
+	for p := range strs {
+		ptrs = append(ptrs, p)
+	}
+	sort.Slice(ptrs, func(i, j int) bool { return ptrs[i] < ptrs[j] })
+
+	var b [1]byte
+	for _, p := range ptrs {
+		r.Strings++
+		if r.FileSize >= 0 && int64(p) >= r.FileSize {
+			r.problem("strings: pointer %d is past the end of the file", p)
+			continue
+		}
+		if _, err := d.f.ReadAt(b[:], int64(p)); err != nil {
+			r.problem("strings: cannot read the length at %d: %v", p, err)
+			continue
+		}
+		if r.FileSize >= 0 && int64(p)+1+int64(b[0]) > r.FileSize {
+			r.problem("strings: string of %d bytes at %d goes past the end of the file", b[0], p)
+		}
+	}
+}
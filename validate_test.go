@@ -0,0 +1,92 @@
+package ip2location_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// a DBReader that cannot tell the size of the file and reads zeros past its end, like a device or a
+// server padding its answers
+type unsizedreader struct {
+	r *bytes.Reader
+}
+
+func (u unsizedreader) Read(p []byte) (int, error) { return u.r.Read(p) }
+func (u unsizedreader) Close() error               { return nil }
+
+func (u unsizedreader) ReadAt(p []byte, off int64) (int, error) {
+	n, _ := u.r.ReadAt(p, off)
+	for i := n; i < len(p); i++ {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestValidate(t *testing.T) {
+	db := opentestdb(t)
+
+	r := db.Validate()
+	if !r.OK() {
+		t.Fatalf("Validate: %s", r)
+	}
+	if r.IPv4Rows == 0 || r.IPv6Rows == 0 || r.IndexEntries != 2*65536 || r.Strings == 0 {
+		t.Errorf("Validate checked too little: %s", r)
+	}
+
+	vr, err := ip2location.ValidateFile(writebin(t, testbin(t)))
+	if err != nil || !vr.OK() {
+		t.Errorf("ValidateFile = %s, %v", vr, err)
+	}
+}
+
+func TestValidateTruncated(t *testing.T) {
+	data := testbin(t)
+	db, err := ip2location.OpenDBWithBytes(data[:len(data)-100])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	r := db.Validate()
+	if r.OK() || !strings.Contains(r.String(), "file size") {
+		t.Errorf("Validate of a truncated file = %s, want a file size problem", r)
+	}
+}
+
+func TestValidateRowCountOverflow(t *testing.T) {
+	data := testbin(t)
+	binary.LittleEndian.PutUint32(data[5:], 0xffffffff) // IPv4 row count
+
+	db, err := ip2location.OpenDBWithBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if r := db.Validate(); r.OK() || r.IPv4Rows != 0 {
+		t.Errorf("Validate with 4294967295 IPv4 rows = %s, want the IPv4 rows rejected", r)
+	}
+
+	db, err = ip2location.OpenDBWithReader(unsizedreader{bytes.NewReader(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	r := db.Validate()
+	if r.OK() || r.IPv4Rows != 0 || r.FileSize != -1 {
+		t.Errorf("Validate with 4294967295 IPv4 rows and no file size = %s, want the IPv4 rows rejected", r)
+	}
+
+	binary.LittleEndian.PutUint32(data[5:], 0x10000000)
+	db, err = ip2location.OpenDBWithReader(unsizedreader{bytes.NewReader(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if r := db.Validate(); r.OK() || r.IPv4Rows != 0 {
+		t.Errorf("Validate with 268435456 IPv4 rows and no file size = %s, want the IPv4 rows rejected", r)
+	}
+}
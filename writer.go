@@ -0,0 +1,440 @@
+package ip2location
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"lukechampine.com/uint128"
+)
+
+const invalid_database_type string = "Invalid database type."
+const too_large_bin string = "Too many ranges for a BIN database."
+
+// one IP range added to a BINWriter, with the CSV values after ip_from and ip_to
+type binrange struct {
+	ipfrom uint128.Uint128
+	ipto   uint128.Uint128
+	values []string
+}
+
+// The BINWriter struct builds an IP2Location BIN database from IP2Location CSV rows,
+// so that custom data can be served by OpenDB like the official databases.
+//
+//	w, err := ip2location.NewBINWriter(3)
+//	...
+//	err = w.ReadCSV(csvfile)
+//	...
+//	err = w.WriteFile("./CUSTOM-DB3.BIN")
+type BINWriter struct {
+	dbtype      uint8
+	productcode uint8
+	date        time.Time
+	cols        []csvcolumn
+	ipv4        []binrange
+	ipv6        []binrange
+}
+
+// NewBINWriter returns a BINWriter for the database type, from 1 (DB1) to 26 (DB26) or one registered
+// with RegisterSchema. The rows it takes have the columns of the IP2Location CSV for that type.
+func NewBINWriter(databasetype int) (*BINWriter, error) {
+	if databasetype < 1 || databasetype > math.MaxUint8 {
+		return nil, errors.New(invalid_database_type)
+	}
+	dbt := uint8(databasetype)
+	schema, ok := Schema(dbt)
+	if !ok {
+		return nil, errors.New(invalid_database_type)
+	}
+	return &BINWriter{dbtype: dbt, productcode: 1, date: time.Now(), cols: typecsvcolumns(schema, FieldAll)}, nil
+}
+
+// SetDate sets the database version written to the header. It defaults to the current date.
+func (w *BINWriter) SetDate(t time.Time) {
+	w.date = t
+}
+
+// SetProductCode sets the product code written to the header. It defaults to 1, the IP2Location databases.
+func (w *BINWriter) SetProductCode(code uint8) {
+	w.productcode = code
+}
+
+// Add adds one IP range given as an IP2Location CSV row: ip_from and ip_to as decimal IP numbers or
+// as IP addresses, then the columns of the database type. Ranges within 0 to 4294967295 or within
+// ::ffff:0:0/96 go to the IPv4 data, the others to the IPv6 data.
+func (w *BINWriter) Add(row []string) error {
+	if len(row) != len(w.cols)+2 {
+		return fmt.Errorf("expected %d columns for DB%d but got %d", len(w.cols)+2, w.dbtype, len(row))
+	}
+
+	fromtype, from, err := parsecsvip(row[0])
+	if err != nil {
+		return err
+	}
+	totype, to, err := parsecsvip(row[1])
+	if err != nil {
+		return err
+	}
+	iptype := uint32(6)
+	switch {
+	case fromtype == 4 && totype == 4:
+		iptype = 4
+	case fromtype == 0 && totype == 0 && to.Cmp(max_ipv4_range) <= 0:
+		iptype = 4 // decimal IP numbers in the IPv4 range
+	case fromtype != 4 && totype != 4:
+		if from.Cmp(from_v4mapped) >= 0 && to.Cmp(to_v4mapped) <= 0 {
+			iptype = 4
+			from = from.And(last_32bits)
+			to = to.And(last_32bits)
+		}
+	default:
+		return fmt.Errorf("ip_from %s and ip_to %s are not of the same IP version", row[0], row[1])
+	}
+	if from.Cmp(to) > 0 {
+		return fmt.Errorf("ip_from %s is after ip_to %s", row[0], row[1])
+	}
+
+	values := make([]string, len(w.cols))
+	for i, c := range w.cols {
+		v := row[i+2]
+		switch c.field {
+		case FieldCountryShort:
+			if len(v) > 2 {
+				return fmt.Errorf("country code %q is longer than 2 characters", v)
+			}
+		case FieldLatitude, FieldLongitude:
+			if _, err := strconv.ParseFloat(v, 32); err != nil {
+				return fmt.Errorf("invalid %s %q", c.name, v)
+			}
+		}
+		if len(v) > 255 {
+			return fmt.Errorf("%s is longer than 255 bytes", c.name)
+		}
+		values[i] = v
+	}
+
+	r := binrange{ipfrom: from, ipto: to, values: values}
+	if iptype == 4 {
+		w.ipv4 = append(w.ipv4, r)
+	} else {
+		w.ipv6 = append(w.ipv6, r)
+	}
+	return nil
+}
+
+// parse ip_from or ip_to, returning 0 as the IP type for decimal IP numbers
+func parsecsvip(s string) (uint32, uint128.Uint128, error) {
+	if strings.ContainsAny(s, ".:") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil || addr.Zone() != "" {
+			return 0, uint128.Zero, fmt.Errorf("invalid IP address %q", s)
+		}
+		iptype, ipnum := addrtonum(addr.Unmap())
+		return iptype, ipnum, nil
+	}
+	ipnum, err := uint128.FromString(s)
+	if err != nil {
+		return 0, uint128.Zero, fmt.Errorf("invalid IP number %q", s)
+	}
+	return 0, ipnum, nil
+}
+
+// AddRecord adds one IP range with the values of the record for the columns of the database type, the other
+// fields of the record being ignored. ip_from and ip_to are given as for Add.
+func (w *BINWriter) AddRecord(ipfrom string, ipto string, x *IP2Locationrecord) error {
+	row := make([]string, 0, len(w.cols)+2)
+	row = append(row, ipfrom, ipto)
+	for _, c := range w.cols {
+		row = append(row, c.value(x))
+	}
+	return w.Add(row)
+}
+
+// ReadCSV adds every row of an IP2Location CSV file, see Add. A first line with the column names,
+// as written by ExportCSV with a header, is skipped.
+func (w *BINWriter) ReadCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // Add reports the wrong column counts
+	cr.ReuseRecord = true
+
+	for first := true; ; first = false {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if first && len(row) > 0 && row[0] == "ip_from" {
+			continue
+		}
+		if err = w.Add(row); err != nil {
+			line, _ := cr.FieldPos(0)
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// returns the ranges sorted, with the gaps between them filled by empty ranges so that they cover
+// the whole IPv4 or IPv6 space
+func (w *BINWriter) fillranges(iptype uint32, ranges []binrange) ([]binrange, error) {
+	maxip := uint128.Max
+	if iptype == 4 {
+		maxip = max_ipv4_range
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].ipfrom.Cmp(ranges[j].ipfrom) < 0 })
+
+	// "-" as in the IP2Location CSV files, so that a gap has no elevation rather than an elevation of 0
+	empty := make([]string, len(w.cols))
+	for i, c := range w.cols {
+		switch c.field {
+		case FieldLatitude, FieldLongitude:
+			empty[i] = "0"
+		default:
+			empty[i] = "-"
+		}
+	}
+
+	var filled []binrange
+	next := uint128.Zero
+	done := false
+	for i, r := range ranges {
+		if done || r.ipfrom.Cmp(next) < 0 {
+			p := ranges[i-1]
+			return nil, fmt.Errorf("range %s - %s overlaps range %s - %s", numtoaddr(iptype, p.ipfrom), numtoaddr(iptype, p.ipto),
+				numtoaddr(iptype, r.ipfrom), numtoaddr(iptype, r.ipto))
+		}
+		if r.ipfrom.Cmp(next) > 0 {
+			filled = append(filled, binrange{ipfrom: next, ipto: r.ipfrom.Sub64(1), values: empty})
+		}
+		filled = append(filled, r)
+		if r.ipto.Cmp(maxip) >= 0 {
+			done = true
+		} else {
+			next = r.ipto.Add64(1)
+		}
+	}
+	if !done {
+		filled = append(filled, binrange{ipfrom: next, ipto: maxip, values: empty})
+	}
+	return filled, nil
+}
+
+// string table of the BIN, storing each distinct string once
+type binstrings struct {
+	base uint32
+	data []byte
+	pos  map[string]uint32
+}
+
+func (s *binstrings) add(v string) uint32 {
+	if p, ok := s.pos[v]; ok {
+		return p
+	}
+	p := s.base + uint32(len(s.data))
+	s.data = append(s.data, byte(len(v)))
+	s.data = append(s.data, v...)
+	s.pos[v] = p
+	return p
+}
+
+// the country name is read 3 bytes after the country code, so both are stored together
+func (s *binstrings) addcountry(short string, long string) uint32 {
+	key := short + "\x00" + long
+	if p, ok := s.pos[key]; ok {
+		return p
+	}
+	p := s.base + uint32(len(s.data))
+	s.data = append(s.data, byte(len(short)))
+	s.data = append(s.data, short...)
+	for len(s.data) < int(p-s.base)+3 {
+		s.data = append(s.data, 0)
+	}
+	s.data = append(s.data, byte(len(long)))
+	s.data = append(s.data, long...)
+	s.pos[key] = p
+	if _, ok := s.pos[short]; !ok {
+		s.pos[short] = p
+	}
+	if _, ok := s.pos[long]; !ok {
+		s.pos[long] = p + 3
+	}
+	return p
+}
+
+// encode the columns of a row after IP From
+func (w *BINWriter) encoderow(buf []byte, r binrange, strs *binstrings) {
+	col := 0
+	for i, c := range w.cols {
+		v := r.values[i]
+		switch c.field {
+		case FieldCountryShort:
+			binary.LittleEndian.PutUint32(buf[col:], strs.addcountry(v, r.values[i+1]))
+		case FieldCountryLong:
+			continue // stored with the country code
+		case FieldLatitude, FieldLongitude:
+			f, _ := strconv.ParseFloat(v, 32)
+			binary.LittleEndian.PutUint32(buf[col:], math.Float32bits(float32(f)))
+		default:
+			binary.LittleEndian.PutUint32(buf[col:], strs.add(v))
+		}
+		col += 4
+	}
+}
+
+// returns the index entries, the first and last row of every range of IP numbers sharing their top 16 bits
+func binindex(ranges []binrange, shift uint) []byte {
+	index := make([]byte, 65536*8)
+	rowof := func(ipnum uint128.Uint128) uint32 {
+		i := sort.Search(len(ranges), func(i int) bool { return ranges[i].ipfrom.Cmp(ipnum) > 0 })
+		return uint32(i - 1)
+	}
+	for b := uint64(0); b < 65536; b++ {
+		first := uint128.From64(b).Lsh(shift)
+		last := first.Add(uint128.Max.Rsh(128 - shift))
+		binary.LittleEndian.PutUint32(index[b*8:], rowof(first))
+		binary.LittleEndian.PutUint32(index[b*8+4:], rowof(last))
+	}
+	return index
+}
+
+// WriteTo writes the BIN database to out. The gaps between the ranges added are filled with empty ranges,
+// holding "-" in every column but the latitude and longitude, which are 0, and the IPv6 data is only written if IPv6 ranges were added. Overlapping ranges are an error.
+func (w *BINWriter) WriteTo(out io.Writer) (int64, error) {
+	ipv4, err := w.fillranges(4, w.ipv4)
+	if err != nil {
+		return 0, err
+	}
+	var ipv6 []binrange
+	if len(w.ipv6) > 0 {
+		if ipv6, err = w.fillranges(6, w.ipv6); err != nil {
+			return 0, err
+		}
+	}
+
+	cols := uint32(1)
+	for _, c := range w.cols {
+		if c.field != FieldCountryLong {
+			cols++
+		}
+	}
+	ipv4colsize := cols << 2
+	ipv6colsize := 16 + ((cols - 1) << 2)
+
+	// header, indexes, IPv4 rows and IPv6 rows, each followed by an end marker row, then the strings
+	var offset uint64 = 64
+	ipv4indexbase := offset
+	offset += 65536 * 8
+	var ipv6indexbase uint64
+	if len(ipv6) > 0 {
+		ipv6indexbase = offset
+		offset += 65536 * 8
+	}
+	ipv4base := offset
+	offset += uint64(len(ipv4)+1) * uint64(ipv4colsize)
+	ipv6base := offset
+	if len(ipv6) > 0 {
+		offset += uint64(len(ipv6)+1) * uint64(ipv6colsize)
+	}
+	if offset > math.MaxUint32 {
+		return 0, errors.New(too_large_bin)
+	}
+
+	// collect the strings first as the header holds the file size
+	strs := &binstrings{base: uint32(offset), pos: make(map[string]uint32)}
+	row := make([]byte, ipv4colsize+ipv6colsize)
+	for _, r := range ipv4 {
+		w.encoderow(row, r, strs)
+	}
+	for _, r := range ipv6 {
+		w.encoderow(row, r, strs)
+	}
+	offset += uint64(len(strs.data))
+	if offset > math.MaxUint32 {
+		return 0, errors.New(too_large_bin)
+	}
+
+	bw := bufio.NewWriter(out)
+	var n int64
+	write := func(b []byte) {
+		if err == nil {
+			var m int
+			m, err = bw.Write(b)
+			n += int64(m)
+		}
+	}
+
+	header := make([]byte, 64)
+	header[0] = w.dbtype
+	header[1] = uint8(cols)
+	header[2] = uint8(w.date.Year() % 100)
+	header[3] = uint8(w.date.Month())
+	header[4] = uint8(w.date.Day())
+	binary.LittleEndian.PutUint32(header[5:], uint32(len(ipv4)))
+	binary.LittleEndian.PutUint32(header[9:], uint32(ipv4base)+1) // addresses in the header start at 1
+	if len(ipv6) > 0 {
+		binary.LittleEndian.PutUint32(header[13:], uint32(len(ipv6)))
+		binary.LittleEndian.PutUint32(header[17:], uint32(ipv6base)+1)
+		binary.LittleEndian.PutUint32(header[25:], uint32(ipv6indexbase)+1)
+	}
+	binary.LittleEndian.PutUint32(header[21:], uint32(ipv4indexbase)+1)
+	header[29] = w.productcode
+	binary.LittleEndian.PutUint32(header[31:], uint32(offset))
+	write(header)
+
+	write(binindex(ipv4, 16))
+	if len(ipv6) > 0 {
+		write(binindex(ipv6, 112))
+	}
+
+	row = row[:ipv4colsize]
+	for _, r := range ipv4 {
+		binary.LittleEndian.PutUint32(row, uint32(r.ipfrom.Lo))
+		w.encoderow(row[4:], r, strs)
+		write(row)
+	}
+	binary.LittleEndian.PutUint32(row, uint32(max_ipv4_range.Lo)) // end marker, giving the IP To of the last range
+	write(row)
+
+	if len(ipv6) > 0 {
+		row = row[:ipv6colsize]
+		for _, r := range ipv6 {
+			r.ipfrom.PutBytes(row)
+			w.encoderow(row[16:], r, strs)
+			write(row)
+		}
+		uint128.Max.PutBytes(row)
+		write(row)
+	}
+
+	write(strs.data)
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+// WriteFile writes the BIN database to the file at the given path.
+func (w *BINWriter) WriteFile(binpath string) error {
+	f, err := os.Create(binpath)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.WriteTo(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package ip2location_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ip2location/ip2location-go/v9"
+)
+
+// exports the database as CSV, builds it again from the CSV with ReadCSV and checks that nothing was lost
+func TestBINWriterReadCSVRoundTrip(t *testing.T) {
+	db := noelevationdb(t)
+	var csv bytes.Buffer
+	if err := db.ExportCSV(&csv, ip2location.CSVExportOptions{Header: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := ip2location.NewBINWriter(26)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.ReadCSV(bytes.NewReader(csv.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if _, err := w.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	rebuilt, err := ip2location.OpenDBWithBytes(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rebuilt.Close()
+
+	var again bytes.Buffer
+	if err := rebuilt.ExportCSV(&again, ip2location.CSVExportOptions{Header: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again.Bytes(), csv.Bytes()) {
+		t.Errorf("the rebuilt database exports\n%s\nwant\n%s", again.Bytes(), csv.Bytes())
+	}
+	for _, ip := range append(cacheips, "9.9.9.9", "192.0.2.1") {
+		want, wanterr := db.Get_all(ip)
+		got, err := rebuilt.Get_all(ip)
+		if err != wanterr || !reflect.DeepEqual(got, want) {
+			t.Errorf("rebuilt Get_all(%s) = %+v, %v, want %+v, %v", ip, got, err, want, wanterr)
+		}
+	}
+	if x, err := rebuilt.Get_all("192.0.2.1"); err != nil || x.Fields&ip2location.FieldElevation != 0 {
+		t.Errorf("rebuilt Get_all(192.0.2.1) = %+v, %v, want no elevation in a gap", x, err)
+	}
+}